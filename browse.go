@@ -0,0 +1,470 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+//go:embed usage_browse.txt
+var BrowseUsage string
+
+// Browser is a collapsible tree view of a document. It only keeps track of
+// what to show; runBrowse connects it to a terminal.
+type Browser struct {
+	Doc    *Document
+	Width  int
+	Height int
+	// Filter is the search path the tree is limited to, if any.
+	Filter    string
+	Filtering bool
+	FilterErr error
+
+	roots    []Result
+	expanded map[string]bool
+	rows     []browserRow
+	cursor   int
+	top      int
+}
+
+type browserRow struct {
+	Result    Result
+	Depth     int
+	Root      bool
+	Container bool
+	Size      int
+}
+
+// NewBrowser starts with the top of the document open.
+func NewBrowser(doc *Document, width, height int) *Browser {
+	b := &Browser{
+		Doc:      doc,
+		Width:    width,
+		Height:   height,
+		expanded: map[string]bool{Location{}.String(): true},
+	}
+	b.applyFilter()
+	return b
+}
+
+// Path is the search path of the value under the cursor.
+func (b *Browser) Path() string {
+	if len(b.rows) == 0 {
+		return ``
+	}
+	return b.rows[b.cursor].Result.Location.String()
+}
+
+// browserChildren lists the children of a container in document order.
+func browserChildren(r Result) []Result {
+	children := make([]Result, 0)
+	switch value := r.Value.(type) {
+	case []any:
+		for idx, item := range value {
+			children = append(children, r.child(item, idx))
+		}
+	case *Map:
+		children = mapChildren(r, value)
+	}
+	return children
+}
+
+func containerSize(value any) (int, bool) {
+	switch v := value.(type) {
+	case []any:
+		return len(v), true
+	case *Map:
+		return v.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+// refresh rebuilds the visible rows after something was opened, closed or
+// filtered, keeping the cursor on the same value if it is still there.
+func (b *Browser) refresh() {
+	path := b.Path()
+	b.rows = b.rows[:0]
+	for _, root := range b.roots {
+		b.addRows(root, 0, true)
+	}
+	b.cursor = 0
+	for idx, row := range b.rows {
+		if row.Result.Location.String() == path {
+			b.cursor = idx
+			break
+		}
+	}
+	b.scroll()
+}
+
+func (b *Browser) addRows(r Result, depth int, root bool) {
+	size, container := containerSize(r.Value)
+	b.rows = append(b.rows, browserRow{Result: r, Depth: depth, Root: root, Container: container, Size: size})
+	if !container || !b.expanded[r.Location.String()] {
+		return
+	}
+	for _, child := range browserChildren(r) {
+		b.addRows(child, depth+1, false)
+	}
+}
+
+func (b *Browser) applyFilter() {
+	if strings.TrimSpace(b.Filter) == `` {
+		b.FilterErr = nil
+		b.roots = []Result{{Value: b.Doc.Data, Location: Location{}}}
+		b.refresh()
+		return
+	}
+	results, err := EvaluateResults(b.Doc.Data, b.Filter)
+	if err != nil {
+		b.FilterErr = err
+		return
+	}
+	b.FilterErr = nil
+	b.roots = results
+	b.refresh()
+}
+
+// treeHeight is the number of lines left for the tree after the status
+// lines.
+func (b *Browser) treeHeight() int {
+	if b.Height < 3 {
+		return 1
+	}
+	return b.Height - 2
+}
+
+func (b *Browser) scroll() {
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+b.treeHeight() {
+		b.top = b.cursor - b.treeHeight() + 1
+	}
+	if b.top < 0 {
+		b.top = 0
+	}
+}
+
+func (b *Browser) move(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.rows) {
+		b.cursor = len(b.rows) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	b.scroll()
+}
+
+func (b *Browser) toggle(open bool) {
+	if len(b.rows) == 0 || !b.rows[b.cursor].Container {
+		return
+	}
+	b.expanded[b.Path()] = open
+	b.refresh()
+}
+
+// HandleKey acts on a key, named as readKeys names them. It reports false
+// when the user wants to quit.
+func (b *Browser) HandleKey(key string) bool {
+	if key == `ctrl-c` {
+		return false
+	}
+	if b.Filtering {
+		switch key {
+		case `enter`:
+			b.Filtering = false
+		case `esc`:
+			b.Filtering = false
+			b.Filter = ``
+			b.applyFilter()
+		case `backspace`:
+			if b.Filter != `` {
+				_, size := utf8.DecodeLastRuneInString(b.Filter)
+				b.Filter = b.Filter[:len(b.Filter)-size]
+				b.applyFilter()
+			}
+		default:
+			if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+				b.Filter += key
+				b.applyFilter()
+			}
+		}
+		return true
+	}
+	switch key {
+	case `q`:
+		return false
+	case `up`, `k`:
+		b.move(-1)
+	case `down`, `j`:
+		b.move(1)
+	case `pgup`:
+		b.move(-b.treeHeight())
+	case `pgdn`:
+		b.move(b.treeHeight())
+	case `home`, `g`:
+		b.move(-len(b.rows))
+	case `end`, `G`:
+		b.move(len(b.rows))
+	case `enter`, ` `:
+		if len(b.rows) != 0 {
+			b.toggle(!b.expanded[b.Path()])
+		}
+	case `right`, `l`:
+		if len(b.rows) == 0 || !b.rows[b.cursor].Container {
+			return true
+		}
+		if b.expanded[b.Path()] {
+			if b.rows[b.cursor].Size != 0 {
+				b.move(1)
+			}
+			return true
+		}
+		b.toggle(true)
+	case `left`, `h`:
+		if len(b.rows) == 0 {
+			return true
+		}
+		if b.rows[b.cursor].Container && b.expanded[b.Path()] {
+			b.toggle(false)
+			return true
+		}
+		depth := b.rows[b.cursor].Depth
+		for idx := b.cursor - 1; idx >= 0; idx-- {
+			if b.rows[idx].Depth < depth {
+				b.cursor = idx
+				b.scroll()
+				break
+			}
+		}
+	case `/`:
+		b.Filtering = true
+	case `esc`:
+		if b.Filter != `` {
+			b.Filter = ``
+			b.applyFilter()
+		}
+	}
+	return true
+}
+
+func (b *Browser) label(row browserRow) string {
+	loc := row.Result.Location
+	var name string
+	switch {
+	case row.Root:
+		name = loc.String()
+	case len(loc) == 0:
+		name = `.`
+	default:
+		switch key := loc[len(loc)-1].(type) {
+		case int:
+			name = fmt.Sprintf(`[%d]`, key)
+		default:
+			name = fmt.Sprint(key)
+		}
+	}
+	marker := `  `
+	if row.Container {
+		marker = `▸ `
+		if b.expanded[loc.String()] {
+			marker = `▾ `
+		}
+	}
+	text := strings.Repeat(`  `, row.Depth) + marker + name
+	switch row.Result.Value.(type) {
+	case []any:
+		return fmt.Sprintf(`%s [%d]`, text, row.Size)
+	case *Map:
+		return fmt.Sprintf(`%s {%d}`, text, row.Size)
+	default:
+		return fmt.Sprintf(`%s: %s`, text, compactJSON(row.Result.Value))
+	}
+}
+
+// fit cuts a line down to the width of the screen.
+func (b *Browser) fit(line string) string {
+	if b.Width <= 0 {
+		return line
+	}
+	runes := []rune(line)
+	if len(runes) <= b.Width {
+		return line
+	}
+	return string(runes[:b.Width-1]) + `…`
+}
+
+// Lines renders the screen as plain lines of text, with the line the cursor
+// is on marked by the second return value.
+func (b *Browser) Lines() ([]string, int) {
+	lines := make([]string, 0, b.Height)
+	for idx := b.top; idx < len(b.rows) && idx < b.top+b.treeHeight(); idx++ {
+		lines = append(lines, b.fit(b.label(b.rows[idx])))
+	}
+	if len(b.rows) == 0 {
+		lines = append(lines, `(nothing found)`)
+	}
+	for len(lines) < b.treeHeight() {
+		lines = append(lines, ``)
+	}
+	lines = append(lines, b.fit(b.Path()))
+	switch {
+	case b.Filtering:
+		lines = append(lines, b.fit(`/`+b.Filter))
+	case b.FilterErr != nil:
+		msg, _, _ := strings.Cut(b.FilterErr.Error(), "\n")
+		lines = append(lines, b.fit(msg))
+	case b.Filter != ``:
+		lines = append(lines, b.fit(fmt.Sprintf(`filter: %s   (esc to remove)`, b.Filter)))
+	default:
+		lines = append(lines, b.fit(`arrows move and open   / filter   q quit`))
+	}
+	return lines, b.cursor - b.top
+}
+
+// Render draws the whole screen with ANSI escape codes.
+func (b *Browser) Render() string {
+	lines, cursor := b.Lines()
+	out := new(strings.Builder)
+	out.WriteString("\x1b[H")
+	for idx, line := range lines {
+		switch {
+		case idx == cursor && len(b.rows) != 0:
+			fmt.Fprintf(out, "\x1b[7m%s\x1b[0m", line)
+		case idx == len(lines)-2:
+			fmt.Fprintf(out, "\x1b[1m%s\x1b[0m", line)
+		default:
+			out.WriteString(line)
+		}
+		out.WriteString("\x1b[K")
+		if idx != len(lines)-1 {
+			out.WriteString("\r\n")
+		}
+	}
+	return out.String()
+}
+
+// readKeys turns what the terminal sends into key names: "up", "down",
+// "left", "right", "pgup", "pgdn", "home", "end", "enter", "esc",
+// "backspace", "ctrl-c", or the character that was typed.
+func readKeys(input []byte) []string {
+	sequences := map[string]string{
+		"\x1b[A": `up`, "\x1b[B": `down`, "\x1b[C": `right`, "\x1b[D": `left`,
+		"\x1bOA": `up`, "\x1bOB": `down`, "\x1bOC": `right`, "\x1bOD": `left`,
+		"\x1b[5~": `pgup`, "\x1b[6~": `pgdn`,
+		"\x1b[H": `home`, "\x1b[F": `end`, "\x1b[1~": `home`, "\x1b[4~": `end`,
+		"\x1bOH": `home`, "\x1bOF": `end`,
+	}
+	keys := make([]string, 0)
+	for len(input) != 0 {
+		if input[0] == 0x1b {
+			matched := false
+			for seq, name := range sequences {
+				if strings.HasPrefix(string(input), seq) {
+					keys = append(keys, name)
+					input = input[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				keys = append(keys, `esc`)
+				input = input[1:]
+			}
+			continue
+		}
+		switch input[0] {
+		case '\r', '\n':
+			keys = append(keys, `enter`)
+		case 0x7f, 0x08:
+			keys = append(keys, `backspace`)
+		case 0x03:
+			keys = append(keys, `ctrl-c`)
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, string(r))
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+func runBrowse(args []string) error {
+	var inputFormat string
+	flags := newFlagSet(`browse`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input file; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	if err := parseFlags(flags, args, BrowseUsage); err != nil {
+		return err
+	}
+
+	filename := flags.Arg(0)
+	if filename == `` {
+		filename = `-`
+	}
+	doc, err := ParseDocument(filename, LookupFormat(inputFormat))
+	if err != nil {
+		return err
+	}
+
+	tty := os.Stdin
+	if filename == `-` || !term.IsTerminal(int(tty.Fd())) {
+		tty, err = os.OpenFile(`/dev/tty`, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf(`could not open the terminal: %w`, err)
+		}
+		defer tty.Close()
+	}
+	var screen io.Writer = os.Stderr
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		screen = os.Stdout
+	}
+	browser := NewBrowser(doc, 80, 24)
+	if err := browseTerminal(browser, tty, screen); err != nil {
+		return err
+	}
+	fmt.Println(browser.Path())
+	return nil
+}
+
+// browseTerminal shows the browser on the alternate screen until the user
+// quits, and puts the terminal back the way it was.
+func browseTerminal(browser *Browser, tty *os.File, screen io.Writer) error {
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf(`could not set up the terminal: %w`, err)
+	}
+	defer term.Restore(fd, state)
+	fmt.Fprint(screen, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(screen, "\x1b[?25h\x1b[?1049l")
+
+	buff := make([]byte, 256)
+	for {
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+			browser.Width, browser.Height = width, height
+			browser.scroll()
+		}
+		fmt.Fprint(screen, browser.Render())
+		n, err := tty.Read(buff)
+		if err != nil {
+			return fmt.Errorf(`could not read from the terminal: %w`, err)
+		}
+		for _, key := range readKeys(buff[:n]) {
+			if !browser.HandleKey(key) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type BrowseTestCase struct {
+	Keys          []string
+	ExpectedPath  string
+	ExpectedLines []string
+}
+
+func (btc BrowseTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	browser := NewBrowser(doc, 40, 8)
+	for _, key := range btc.Keys {
+		assert.True(t, browser.HandleKey(key), key)
+	}
+	assert.Equal(t, btc.ExpectedPath, browser.Path(), btc.Keys)
+	if btc.ExpectedLines != nil {
+		lines, _ := browser.Lines()
+		assert.Equal(t, btc.ExpectedLines, lines, btc.Keys)
+	}
+}
+
+var BrowseTestCases = []BrowseTestCase{
+	{
+		Keys:         []string{},
+		ExpectedPath: `.`,
+		ExpectedLines: []string{
+			`▾ . {4}`,
+			`  ▸ meta {1}`,
+			`  ▸ animals {2}`,
+			`  ▸ vegetables {2}`,
+			`  ▸ minerals {3}`,
+			``,
+			`.`,
+			`arrows move and open   / filter   q quit`,
+		},
+	},
+	{
+		Keys:         []string{`down`, `down`, `down`, `down`, `right`, `right`, `right`, `down`},
+		ExpectedPath: `minerals.igneous[0]`,
+		ExpectedLines: []string{
+			`  ▸ meta {1}`,
+			`  ▸ animals {2}`,
+			`  ▸ vegetables {2}`,
+			`  ▾ minerals {3}`,
+			`    ▾ igneous [3]`,
+			`        [0]: "obsidian"`,
+			`minerals.igneous[0]`,
+			`arrows move and open   / filter   q quit`,
+		},
+	},
+	{
+		Keys:         []string{`j`, `j`, `j`, `j`, `l`, `l`, `l`, `j`, `left`, `left`},
+		ExpectedPath: `minerals.igneous`,
+	},
+	{
+		Keys:         []string{`j`, `enter`, `enter`, `G`},
+		ExpectedPath: `minerals`,
+	},
+	{
+		Keys:         []string{`G`, `g`, `up`},
+		ExpectedPath: `.`,
+	},
+	{
+		Keys:         []string{`/`, `a`, `n`, `i`, `m`, `a`, `l`, `s`, `.`, `v`, `e`, `r`, `t`, `e`, `b`, `r`, `a`, `t`, `e`, `s`, `.`, `m`, `a`, `m`, `m`, `a`, `l`, `s`, `[`, `*`, `]`, `enter`, `down`},
+		ExpectedPath: `animals.vertebrates.mammals[1]`,
+		ExpectedLines: []string{
+			`  animals.vertebrates.mammals[0]: "hors…`,
+			`  animals.vertebrates.mammals[1]: "shre…`,
+			`  animals.vertebrates.mammals[2]: "cat"`,
+			``,
+			``,
+			``,
+			`animals.vertebrates.mammals[1]`,
+			`filter: animals.vertebrates.mammals[*] …`,
+		},
+	},
+	{
+		Keys:         []string{`/`, `x`, `.`, `l`, `e`, `n`, `(`},
+		ExpectedPath: ``,
+		ExpectedLines: []string{
+			`(nothing found)`,
+			``,
+			``,
+			``,
+			``,
+			``,
+			``,
+			`/x.len(`,
+		},
+	},
+	{
+		Keys:         []string{`/`, `x`, `esc`},
+		ExpectedPath: `.`,
+	},
+	{
+		Keys:         []string{`/`, `m`, `e`, `t`, `a`, `enter`, `esc`, `j`},
+		ExpectedPath: `animals`,
+	},
+}
+
+func TestBrowse(t *testing.T) {
+	for _, tc := range BrowseTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestBrowseQuit(t *testing.T) {
+	browser := NewBrowser(&Document{Data: NewMap()}, 40, 8)
+	assert.False(t, browser.HandleKey(`q`))
+	browser.HandleKey(`/`)
+	assert.True(t, browser.HandleKey(`q`))
+	assert.False(t, browser.HandleKey(`ctrl-c`))
+}
+
+func TestReadKeys(t *testing.T) {
+	assert.Equal(t,
+		[]string{`up`, `down`, `pgdn`, `esc`, `a`, `é`, `enter`, `backspace`, `ctrl-c`},
+		readKeys([]byte("\x1b[A\x1bOB\x1b[6~\x1baé\r\x7f\x03")),
+	)
+}
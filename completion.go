@@ -0,0 +1,233 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"golang.org/x/exp/slices"
+)
+
+//go:embed usage_completion.txt
+var CompletionUsage string
+
+//go:embed completion.bash.tmpl
+var BashCompletion string
+
+//go:embed completion.zsh.tmpl
+var ZshCompletion string
+
+//go:embed completion.fish.tmpl
+var FishCompletion string
+
+// completionValues are the choices for options that only take a few values.
+var completionValues = map[string][]string{
+	`format`:        {`json`, `yaml`},
+	`error-format`:  {`text`, `json`},
+	`template-mode`: {`each`, `all`},
+}
+
+// completionFiles are the options that take a filename.
+var completionFiles = map[string]bool{
+	`in`:            true,
+	`out`:           true,
+	`template-file`: true,
+	`patch`:         true,
+}
+
+// completionOption is one option from defineFlags, with its long and short
+// names together.
+type completionOption struct {
+	Long   string
+	Short  string
+	Usage  string
+	Bool   bool
+	File   bool
+	Search bool
+	Values []string
+}
+
+// Names lists the ways the option can be written on the command line.
+func (co completionOption) Names() []string {
+	names := []string{`--` + co.Long}
+	if co.Short != `` {
+		names = append(names, `-`+co.Short)
+	}
+	return names
+}
+
+func completionOptions() []completionOption {
+	fs := newFlagSet(`stool`)
+	defineFlags(fs)
+	byValue := make(map[flag.Value]*completionOption)
+	fs.VisitAll(func(f *flag.Flag) {
+		option, ok := byValue[f.Value]
+		if !ok {
+			option = &completionOption{Usage: f.Usage}
+			if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+				option.Bool = bf.IsBoolFlag()
+			}
+			byValue[f.Value] = option
+		}
+		if len(f.Name) == 1 {
+			option.Short = f.Name
+		} else {
+			option.Long = f.Name
+		}
+	})
+	options := make([]completionOption, 0, len(byValue))
+	for _, option := range byValue {
+		option.File = completionFiles[option.Long]
+		option.Search = option.Long == `search`
+		option.Values = completionValues[option.Long]
+		options = append(options, *option)
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Long < options[j].Long
+	})
+	return options
+}
+
+func completionCommands() []string {
+	commands := make([]string, 0, len(Commands))
+	for name := range Commands {
+		if !strings.HasPrefix(name, `__`) {
+			commands = append(commands, name)
+		}
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// shellPatterns joins the names of options into a case pattern that also
+// matches the single-dash form of long options, which the flag package
+// accepts too.
+func shellPatterns(options []completionOption, sep string) string {
+	patterns := make([]string, 0)
+	for _, option := range options {
+		patterns = append(patterns, `--`+option.Long, `-`+option.Long)
+		if option.Short != `` {
+			patterns = append(patterns, `-`+option.Short)
+		}
+	}
+	return strings.Join(patterns, sep)
+}
+
+// completionData is what the completion script templates are rendered with.
+type completionData struct {
+	Commands     []string
+	FileCommands []string
+	Options      []completionOption
+	Input        []completionOption
+	TakeValues   []completionOption
+}
+
+func newCompletionData() completionData {
+	data := completionData{
+		Commands: completionCommands(),
+		Options:  completionOptions(),
+	}
+	data.FileCommands = without(data.Commands, `completion`, `query`)
+	for _, option := range data.Options {
+		switch {
+		case option.Long == `in`:
+			data.Input = append(data.Input, option)
+		case !option.Bool:
+			data.TakeValues = append(data.TakeValues, option)
+		}
+	}
+	return data
+}
+
+var completionFuncs = template.FuncMap{
+	`join`:     strings.Join,
+	`patterns`: shellPatterns,
+	`list`: func(options ...completionOption) []completionOption {
+		return options
+	},
+	`names`: func(options []completionOption) string {
+		names := make([]string, 0)
+		for _, option := range options {
+			names = append(names, option.Names()...)
+		}
+		return strings.Join(names, ` `)
+	},
+	`zshEscape`:  strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace,
+	`fishEscape`: strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace,
+}
+
+// WriteCompletion writes the completion script for a shell, which may be
+// bash, zsh or fish.
+func WriteCompletion(w io.Writer, shell string) error {
+	var script string
+	switch shell {
+	case `bash`:
+		script = BashCompletion
+	case `zsh`:
+		script = ZshCompletion
+	case `fish`:
+		script = FishCompletion
+	default:
+		return fmt.Errorf(`no completion script for %q; use bash, zsh or fish`, shell)
+	}
+	tmplt, err := template.New(shell).Funcs(completionFuncs).Parse(script)
+	if err != nil {
+		return err
+	}
+	return tmplt.Execute(w, newCompletionData())
+}
+
+func without(words []string, drop ...string) []string {
+	out := make([]string, 0, len(words))
+	for _, word := range words {
+		if !slices.Contains(drop, word) {
+			out = append(out, word)
+		}
+	}
+	return out
+}
+
+func runCompletion(args []string) error {
+	flags := newFlagSet(`completion`)
+	if err := parseFlags(flags, args, CompletionUsage); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return &UsageError{Err: fmt.Errorf(`completion needs the name of a shell: bash, zsh or fish`)}
+	}
+	return WriteCompletion(os.Stdout, flags.Arg(0))
+}
+
+// runCompleteSearch is used by the completion scripts to list the ways a
+// partly typed search path could go on in a file. It never reports errors,
+// because there is nowhere for the shell to show them.
+func runCompleteSearch(args []string) error {
+	var inputFormat string
+	flags := flag.NewFlagSet(`__complete-search`, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input file`)
+	aliasFlag(flags, `format`, `f`)
+	if err := flags.Parse(args); err != nil {
+		return nil
+	}
+	filename, partial := flags.Arg(0), flags.Arg(1)
+	if filename == `` || filename == `-` {
+		return nil
+	}
+	log.SetOutput(io.Discard)
+	doc, err := ParseDocument(filename, LookupFormat(inputFormat))
+	if err != nil {
+		return nil
+	}
+	for _, candidate := range CompleteMember(doc.Data, partial) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type CompletionTestCase struct {
+	Shell    string
+	Expected []string
+}
+
+func (ctc CompletionTestCase) Test(t *testing.T) {
+	t.Helper()
+	buff := new(strings.Builder)
+	assert.NoError(t, WriteCompletion(buff, ctc.Shell))
+	script := buff.String()
+	for _, expected := range ctc.Expected {
+		assert.Contains(t, script, expected, ctc.Shell)
+	}
+	assert.NotContains(t, script, `<no value>`, ctc.Shell)
+}
+
+var CompletionTestCases = []CompletionTestCase{
+	{
+		Shell: `bash`,
+		Expected: []string{
+			`complete -F _stool stool`,
+			`--in|-in|-i) skip=input ;;`,
+			`--format|-format|-f) COMPREPLY=($(compgen -W "json yaml" -- "$cur")); return ;;`,
+			`--error-format|-error-format) COMPREPLY=($(compgen -W "text json" -- "$cur")); return ;;`,
+			`stool __complete-search`,
+			`--with-location -L`,
+		},
+	},
+	{
+		Shell: `zsh`,
+		Expected: []string{
+			`#compdef stool`,
+			`'(--format -f)'{--format,-f}'[`,
+			`:format:(json yaml)' \`,
+			`:search path:_stool_search' \`,
+			`'(--strict)'--strict'[fail if any part of the search path finds nothing]' \`,
+		},
+	},
+	{
+		Shell: `fish`,
+		Expected: []string{
+			`-l format -s f -x -a 'json yaml'`,
+			`-l in -s i -r -F`,
+			`(stool __complete-search (__stool_input) (commandline -ct) 2>/dev/null)`,
+			`-l strict -d 'fail if any part of the search path finds nothing'`,
+		},
+	},
+}
+
+func TestCompletion(t *testing.T) {
+	for _, tc := range CompletionTestCases {
+		tc.Test(t)
+	}
+	assert.Error(t, WriteCompletion(new(strings.Builder), `tcsh`))
+}
+
+func TestCompletionOptions(t *testing.T) {
+	options := make(map[string]completionOption)
+	for _, option := range completionOptions() {
+		options[option.Long] = option
+	}
+	assert.Equal(t, `f`, options[`format`].Short)
+	assert.Equal(t, []string{`json`, `yaml`}, options[`format`].Values)
+	assert.True(t, options[`in`].File)
+	assert.True(t, options[`search`].Search)
+	assert.True(t, options[`strict`].Bool)
+	assert.Equal(t, ``, options[`strict`].Short)
+	assert.Equal(t, []string{`--paths`, `-P`}, options[`paths`].Names())
+	assert.NotContains(t, completionCommands(), `__complete-search`)
+}
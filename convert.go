@@ -0,0 +1,74 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+//go:embed usage_convert.txt
+var ConvertUsage string
+
+// ConvertDocument renders a document in another format. If the format is
+// FormatUnknown, JSON is written as YAML and anything else as JSON.
+func ConvertDocument(doc *Document, format Format) ([]byte, error) {
+	if format == FormatUnknown {
+		format = FormatJSON
+		if doc.Format == FormatJSON {
+			format = FormatYAML
+		}
+	}
+	return Marshal(doc.Data, format)
+}
+
+func runConvert(args []string) error {
+	var (
+		inputFormat  string
+		outputFormat string
+		outputFile   = `-`
+	)
+	flags := newFlagSet(`convert`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input file; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.StringVar(&outputFormat, `output-format`, outputFormat, `the format to write; defaults to the one the input isn't`)
+	aliasFlag(flags, `output-format`, `F`)
+	flags.StringVar(&outputFile, `out`, outputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(flags, `out`, `o`)
+	defineStyleFlags(flags)
+	if err := parseFlags(flags, args, ConvertUsage); err != nil {
+		return err
+	}
+
+	if err := OutputStyle.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+
+	if flags.NArg() > 2 {
+		return &UsageError{Err: fmt.Errorf(`convert takes an input file and an output file, not %d files`, flags.NArg())}
+	}
+	inputFile := `-`
+	if flags.Arg(0) != `` {
+		inputFile = flags.Arg(0)
+	}
+	if outfile := flags.Arg(1); outputFile == `-` && outfile != `` {
+		outputFile = outfile
+	}
+	format := LookupFormat(outputFormat)
+	if outputFormat != `` && format == FormatUnknown {
+		return &UsageError{Err: fmt.Errorf(`unknown output format %q; use yaml or json`, outputFormat)}
+	}
+	doc, err := ParseDocument(inputFile, LookupFormat(inputFormat))
+	if err != nil {
+		return err
+	}
+	out, err := ConvertDocument(doc, format)
+	if err != nil {
+		return fmt.Errorf(`could not render %q: %w`, inputFile, err)
+	}
+	if outputFile == `-` {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(outputFile, out, 0644)
+}
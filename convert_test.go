@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ConvertTestCase struct {
+	Filename       string
+	Format         Format
+	ExpectedFormat Format
+}
+
+func (ctc ConvertTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(ctc.Filename, FormatUnknown)
+	assert.NoError(t, err, ctc.Filename)
+	out, err := ConvertDocument(doc, ctc.Format)
+	assert.NoError(t, err, ctc.Filename)
+
+	converted := filepath.Join(t.TempDir(), `converted`)
+	assert.NoError(t, ioutil.WriteFile(converted, out, 0644))
+	reread, err := ParseDocument(converted, ctc.ExpectedFormat)
+	assert.NoError(t, err, ctc.Filename)
+	assert.Equal(t, ctc.ExpectedFormat, reread.Format, ctc.Filename)
+	assert.Equal(t, doc.Data, reread.Data, ctc.Filename)
+}
+
+var ConvertTestCases = []ConvertTestCase{
+	{
+		Filename:       `test_data/test.yaml`,
+		ExpectedFormat: FormatJSON,
+	},
+	{
+		Filename:       `test_data/test.json`,
+		ExpectedFormat: FormatYAML,
+	},
+	{
+		Filename:       `test_data/test.json`,
+		Format:         FormatJSON,
+		ExpectedFormat: FormatJSON,
+	},
+}
+
+func TestConvert(t *testing.T) {
+	for _, tc := range ConvertTestCases {
+		tc.Test(t)
+	}
+}
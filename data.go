@@ -1,19 +1,83 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
-
-	yaml "gopkg.in/yaml.v3"
+	"strings"
 )
 
-type Unmarshaller func([]byte, any) error
+// Unmarshaller parses a structured file into the document value model
+// described in value.go.
+type Unmarshaller func([]byte) (any, error)
+
+// Document is a parsed structured file along with where it came from.
+type Document struct {
+	Filename string
+	Format   Format
+	Data     any
+	Source   []byte
+	// Patched is set once Data has been changed by --patch, so it no
+	// longer matches Source and has no positions.
+	Patched bool
+
+	positions Positions
+}
+
+// Position finds where a value starts in the source file.
+func (d *Document) Position(loc Location) (Position, bool) {
+	if d.Patched || loc.Derived() {
+		return Position{}, false
+	}
+	if d.positions == nil {
+		var (
+			positions Positions
+			err       error
+		)
+		if d.Format == FormatJSON {
+			positions, err = jsonPositions(d.Source)
+		}
+		if d.Format != FormatJSON || err != nil {
+			positions, err = yamlPositions(d.Source)
+		}
+		if err != nil {
+			positions = Positions{}
+		}
+		d.positions = positions
+	}
+	pos, ok := d.positions[loc.Pointer()]
+	return pos, ok
+}
+
+// Locate finds the file, line and column where a value starts.
+func (d *Document) Locate(loc Location) SourcePosition {
+	filename := d.Filename
+	if filename == `-` {
+		filename = `<stdin>`
+	}
+	pos, _ := d.Position(loc)
+	return SourcePosition{Filename: filename, Position: pos}
+}
+
+// LookupFormat interprets a user-supplied format name. Anything it doesn't
+// recognize is FormatUnknown, which means "auto-detect" when reading.
+func LookupFormat(name string) Format {
+	switch strings.ToLower(name) {
+	case `json`, `j`, `js`:
+		return FormatJSON
+	case `yaml`, `yml`, `y`:
+		return FormatYAML
+	default:
+		return FormatUnknown
+	}
+}
 
-func Parse(filename string, format Format) (map[string]any, error) {
+// ParseDocument reads and unmarshals a structured file, or STDIN if the
+// filename is "-". Unlike Parse, the top level of the document can be any
+// kind of value.
+func ParseDocument(filename string, format Format) (*Document, error) {
 	var (
 		err    error
 		reader io.Reader
@@ -25,6 +89,7 @@ func Parse(filename string, format Format) (map[string]any, error) {
 		if err != nil {
 			return nil, fmt.Errorf(`could not open %q: %w`, filename, err)
 		}
+		defer file.Close()
 		reader = file
 	}
 	data, err := ioutil.ReadAll(reader)
@@ -34,25 +99,66 @@ func Parse(filename string, format Format) (map[string]any, error) {
 	if format == FormatUnknown {
 		format, err = Detect(data)
 		if err != nil {
-			return nil, fmt.Errorf(`could not determine format of %q: %w`, filename, err)
+			return nil, &ParseError{Filename: filename, Err: fmt.Errorf(`could not determine format: %w`, err)}
 		}
 	}
-	var unmarshallers []Unmarshaller
+	unmarshallers := map[Format]Unmarshaller{
+		FormatJSON: decodeJSON,
+		FormatYAML: decodeYAML,
+	}
+	var attempts []Format
 	switch format {
 	case FormatJSON:
-		unmarshallers = []Unmarshaller{json.Unmarshal, yaml.Unmarshal}
+		attempts = []Format{FormatJSON, FormatYAML}
 	case FormatYAML:
-		unmarshallers = []Unmarshaller{yaml.Unmarshal, json.Unmarshal}
+		attempts = []Format{FormatYAML, FormatJSON}
 	default:
-		return nil, fmt.Errorf(`could not determine format of %q`, filename)
+		return nil, &ParseError{Filename: filename, Err: fmt.Errorf(`could not determine format`)}
 	}
-	parsed := make(map[string]any)
-	for _, um := range unmarshallers {
-		err = um(data, &parsed)
+	var firstErr error
+	for _, attempt := range attempts {
+		parsed, err := unmarshallers[attempt](data)
+		if firstErr == nil {
+			firstErr = err
+		}
 		if err == nil {
-			return parsed, nil
+			return &Document{
+				Filename: filename,
+				Format:   format,
+				Data:     parsed,
+				Source:   data,
+			}, nil
+		}
+		log.Printf(`WARN: unable to parse %q as %s: %s`, filename, attempt, err.Error())
+	}
+	return nil, &ParseError{Filename: filename, Format: format, Err: firstErr}
+}
+
+// Parse reads a structured file whose top level must be a map.
+func Parse(filename string, format Format) (*Map, error) {
+	doc, err := ParseDocument(filename, format)
+	if err != nil {
+		return nil, err
+	}
+	parsed, ok := doc.Data.(*Map)
+	if !ok {
+		return nil, &ParseError{Filename: filename, Format: doc.Format, Err: fmt.Errorf(`the top level is not a map`)}
+	}
+	return parsed, nil
+}
+
+// Marshal renders data in the given structured format, in OutputStyle.
+func Marshal(data any, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		b, err := OutputStyle.JSON(data, true)
+		if err != nil {
+			return nil, err
 		}
-		log.Printf(`WARN: unable to parse %q as %s: %s`, filename, format, err.Error())
+		return append(b, '\n'), nil
+	case FormatYAML:
+		return OutputStyle.YAML(data)
+	default:
+		return nil, fmt.Errorf(`don't know how to write %s`, format)
 	}
-	return nil, fmt.Errorf(`no unmarshallers were able to parse %q`, filename)
 }
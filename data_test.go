@@ -32,8 +32,9 @@ var DataTestCases = []DataTestCase{
 
 func (tc DataTestCase) Test(t *testing.T) {
 	t.Helper()
-	data, err := Parse(tc.Filename, tc.Format)
+	parsed, err := Parse(tc.Filename, tc.Format)
 	assert.NoError(t, err)
+	data := Plain(parsed).(map[string]any)
 	for _, key := range []string{`animals`, `vegetables`, `minerals`} {
 		assert.Contains(t, data, key)
 	}
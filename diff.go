@@ -0,0 +1,251 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+//go:embed usage_diff.txt
+var DiffUsage string
+
+// ChangeType describes how a value differs between two documents.
+type ChangeType int
+
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRemoved
+	ChangeChanged
+)
+
+// Change is a single difference between two documents.
+type Change struct {
+	Type     ChangeType
+	Location Location
+	Old      any
+	New      any
+}
+
+// Diff compares two documents structurally and lists the values that were
+// added, removed or changed to get from left to right. Numbers compare by
+// value, so a document compares equal to itself converted between formats.
+func Diff(left, right any) []Change {
+	return diffValues(Location{}, left, right, nil)
+}
+
+func diffValues(loc Location, left, right any, changes []Change) []Change {
+	switch lv := left.(type) {
+	case *Map:
+		if rv, ok := right.(*Map); ok {
+			// Members are compared in the left document's order, followed
+			// by those only the right one has, in its order.
+			keys := lv.Keys()
+			for _, key := range rv.Keys() {
+				if _, ok := lv.Get(key); !ok {
+					keys = append(keys, key)
+				}
+			}
+			for _, key := range keys {
+				lval, lok := lv.Get(key)
+				rval, rok := rv.Get(key)
+				changes = diffMember(loc.Child(key), lval, lok, rval, rok, changes)
+			}
+			return changes
+		}
+	case []any:
+		if rv, ok := right.([]any); ok {
+			for idx := 0; idx < len(lv) && idx < len(rv); idx++ {
+				changes = diffValues(loc.Child(idx), lv[idx], rv[idx], changes)
+			}
+			for idx := len(lv); idx < len(rv); idx++ {
+				changes = append(changes, Change{Type: ChangeAdded, Location: loc.Child(idx), New: rv[idx]})
+			}
+			// Removals run from the end so that the indexes stay valid
+			// when the changes are applied in order.
+			for idx := len(lv) - 1; idx >= len(rv); idx-- {
+				changes = append(changes, Change{Type: ChangeRemoved, Location: loc.Child(idx), Old: lv[idx]})
+			}
+			return changes
+		}
+	}
+	if !scalarsEqual(left, right) {
+		changes = append(changes, Change{Type: ChangeChanged, Location: loc, Old: left, New: right})
+	}
+	return changes
+}
+
+func diffMember(loc Location, lval any, lok bool, rval any, rok bool, changes []Change) []Change {
+	switch {
+	case lok && !rok:
+		return append(changes, Change{Type: ChangeRemoved, Location: loc, Old: lval})
+	case !lok && rok:
+		return append(changes, Change{Type: ChangeAdded, Location: loc, New: rval})
+	default:
+		return diffValues(loc, lval, rval, changes)
+	}
+}
+
+// scalarsEqual compares two values, treating all numeric types as
+// interchangeable.
+func scalarsEqual(left, right any) bool {
+	lnum, lok := asRat(left)
+	rnum, rok := asRat(right)
+	if lok && rok {
+		return lnum.Cmp(rnum) == 0
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// WriteDiffHuman lists the changes one per line, marking additions with
+// "+", removals with "-" and changes with "~".
+func WriteDiffHuman(w io.Writer, changes []Change) error {
+	for _, change := range changes {
+		var err error
+		switch change.Type {
+		case ChangeAdded:
+			_, err = fmt.Fprintf(w, "+ %s: %s\n", change.Location, compactJSON(change.New))
+		case ChangeRemoved:
+			_, err = fmt.Fprintf(w, "- %s: %s\n", change.Location, compactJSON(change.Old))
+		case ChangeChanged:
+			_, err = fmt.Fprintf(w, "~ %s: %s -> %s\n", change.Location, compactJSON(change.Old), compactJSON(change.New))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compactJSON(value any) string {
+	b, err := OutputStyle.JSON(value, false)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+type jsonChange struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// WriteDiffJSON writes the changes as a JSON array of objects with the
+// operation, the stool path, and the old and new values.
+func WriteDiffJSON(w io.Writer, changes []Change) error {
+	out := make([]jsonChange, 0, len(changes))
+	for _, change := range changes {
+		jc := jsonChange{
+			Path: change.Location.String(),
+			Old:  change.Old,
+			New:  change.New,
+		}
+		switch change.Type {
+		case ChangeAdded:
+			jc.Op = `added`
+		case ChangeRemoved:
+			jc.Op = `removed`
+		case ChangeChanged:
+			jc.Op = `changed`
+		}
+		out = append(out, jc)
+	}
+	return writeIndentedJSON(w, out)
+}
+
+// WriteDiffPatch writes the changes as an RFC 6902 JSON Patch that
+// transforms the left document into the right one.
+func WriteDiffPatch(w io.Writer, changes []Change) error {
+	out := make([]map[string]any, 0, len(changes))
+	for _, change := range changes {
+		op := map[string]any{`path`: change.Location.Pointer()}
+		switch change.Type {
+		case ChangeAdded:
+			op[`op`] = `add`
+			op[`value`] = change.New
+		case ChangeRemoved:
+			op[`op`] = `remove`
+		case ChangeChanged:
+			op[`op`] = `replace`
+			op[`value`] = change.New
+		}
+		out = append(out, op)
+	}
+	return writeIndentedJSON(w, out)
+}
+
+func writeIndentedJSON(w io.Writer, value any) error {
+	b, err := OutputStyle.JSON(value, true)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func runDiff(args []string) error {
+	var (
+		inputFormat  string
+		outputFormat = `human`
+		outputFile   = `-`
+	)
+	flags := newFlagSet(`diff`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input files; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.StringVar(&outputFormat, `output-format`, outputFormat, `how to report the differences; human|json|patch`)
+	aliasFlag(flags, `output-format`, `F`)
+	flags.StringVar(&outputFile, `out`, outputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(flags, `out`, `o`)
+	defineStyleFlags(flags)
+	if err := parseFlags(flags, args, DiffUsage); err != nil {
+		return err
+	}
+	if err := OutputStyle.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+
+	var write func(io.Writer, []Change) error
+	switch strings.ToLower(outputFormat) {
+	case `human`, `text`:
+		write = WriteDiffHuman
+	case `json`, `j`, `js`:
+		write = WriteDiffJSON
+	case `patch`, `json-patch`, `jsonpatch`:
+		write = WriteDiffPatch
+	default:
+		return &UsageError{Err: fmt.Errorf(`unknown diff output format %q`, outputFormat)}
+	}
+	if flags.NArg() != 2 {
+		return &UsageError{Err: fmt.Errorf(`diff needs exactly two files to compare`)}
+	}
+	left, err := ParseDocument(flags.Arg(0), LookupFormat(inputFormat))
+	if err != nil {
+		return err
+	}
+	right, err := ParseDocument(flags.Arg(1), LookupFormat(inputFormat))
+	if err != nil {
+		return err
+	}
+	changes := Diff(left.Data, right.Data)
+
+	var w io.Writer = os.Stdout
+	if outputFile != `-` {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf(`could not create %q: %w`, outputFile, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := write(w, changes); err != nil {
+		return fmt.Errorf(`could not write differences: %w`, err)
+	}
+	if len(changes) != 0 {
+		return ExitStatus(1)
+	}
+	return nil
+}
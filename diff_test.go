@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type DiffTestCase struct {
+	Left     any
+	Right    any
+	Human    string
+	Patch    string
+	Expected []Change
+}
+
+func (dtc DiffTestCase) Test(t *testing.T) {
+	t.Helper()
+	changes := Diff(Normalize(dtc.Left), Normalize(dtc.Right))
+	if dtc.Human == `` && dtc.Patch == `` {
+		assert.Equal(t, dtc.Expected, changes)
+	}
+	if dtc.Human != `` {
+		buff := new(bytes.Buffer)
+		assert.NoError(t, WriteDiffHuman(buff, changes))
+		assert.Equal(t, dtc.Human, buff.String())
+	}
+	if dtc.Patch != `` {
+		buff := new(bytes.Buffer)
+		assert.NoError(t, WriteDiffPatch(buff, changes))
+		assert.JSONEq(t, dtc.Patch, buff.String())
+	}
+}
+
+func parseTestDocument(filename string, format Format) any {
+	doc, err := ParseDocument(filename, format)
+	if err != nil {
+		panic(err)
+	}
+	return doc.Data
+}
+
+func mergeTestDocuments(filenames ...string) any {
+	docs := make([]*Document, 0, len(filenames))
+	for _, filename := range filenames {
+		doc, err := ParseDocument(filename, FormatYAML)
+		if err != nil {
+			panic(err)
+		}
+		docs = append(docs, doc)
+	}
+	return MergeDocuments(docs, DefaultMergeOptions)
+}
+
+var DiffTestCases = []DiffTestCase{
+	{
+		Left:  parseTestDocument(`test_data/test.yaml`, FormatYAML),
+		Right: parseTestDocument(`test_data/test.json`, FormatJSON),
+		Human: `- meta: {"description":"{\n  \"version\": \"1.0.0\",\n  \"type\": \"yaml document\",\n  \"name\": \"test_data/test.yaml\"\n}\n"}
+`,
+	},
+	{
+		Left:     parseTestDocument(`test_data/test.yaml`, FormatYAML),
+		Right:    parseTestDocument(`test_data/test.yaml`, FormatYAML),
+		Expected: []Change(nil),
+	},
+	{
+		Left:     map[string]any{`meta`: map[string]any{`version`: 1}},
+		Right:    map[string]any{`meta`: map[string]any{`version`: 1.0}},
+		Expected: []Change(nil),
+	},
+	{
+		Left:  map[string]any{`meta`: map[string]any{`version`: 1}},
+		Right: map[string]any{`meta`: map[string]any{`version`: `1`}},
+		Expected: []Change{
+			{Type: ChangeChanged, Location: Location{`meta`, `version`}, Old: 1, New: `1`},
+		},
+	},
+	{
+		Left:  map[string]any{`a`: []any{1, 2}, `key with spaces`: `x`, `b~/c`: true},
+		Right: map[string]any{`a`: []any{1}, `key with spaces`: `y`, `b~/c`: false, `10`: nil},
+		Human: `- a[1]: 2
+~ ["b~/c"]: true -> false
+~ ["key with spaces"]: "x" -> "y"
++ ["10"]: null
+`,
+		Patch: `[
+			{"op": "remove", "path": "/a/1"},
+			{"op": "replace", "path": "/b~0~1c", "value": false},
+			{"op": "replace", "path": "/key with spaces", "value": "y"},
+			{"op": "add", "path": "/10", "value": null}
+		]`,
+	},
+	{
+		Left:  parseTestDocument(`test_data/test.yaml`, FormatYAML),
+		Right: mergeTestDocuments(`test_data/test.yaml`, `test_data/overlay.yaml`),
+		Human: `~ animals.vertebrates.mammals[0]: "horse" -> "whale"
+- animals.vertebrates.mammals[2]: "cat"
+- animals.vertebrates.mammals[1]: "shrew"
++ animals.vertebrates.birds: ["robin","crow"]
+- animals.invertebrates: {"mollusks":["clam"],"insects":["fly","ant"]}
+~ vegetables.flowers[0]: "rose" -> "daisy"
+- vegetables.flowers[3]: "narcissus"
+- vegetables.flowers[2]: "tulip"
+- vegetables.flowers[1]: "magnolia"
+- minerals.metamorphic: ["slate","schist","marble"]
+`,
+	},
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range DiffTestCases {
+		tc.Test(t)
+	}
+
+	defer func(style Style) { OutputStyle = style }(OutputStyle)
+	OutputStyle = Style{Tabs: true, Indent: 4}
+	changes := Diff(Normalize(map[string]any{`a`: `<b>`}), Normalize(map[string]any{}))
+	buff := new(bytes.Buffer)
+	assert.NoError(t, WriteDiffJSON(buff, changes))
+	assert.Equal(t, "[\n\t{\n\t\t\"op\": \"removed\",\n\t\t\"path\": \"a\",\n\t\t\"old\": \"<b>\"\n\t}\n]\n", buff.String())
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// UsageError is returned when the command line can't be understood.
+type UsageError struct {
+	Err error
+}
+
+func (ue *UsageError) Error() string {
+	return ue.Err.Error()
+}
+
+func (ue *UsageError) Unwrap() error {
+	return ue.Err
+}
+
+// StatusError gives an error the exit status to end the program with, for
+// errors whose type doesn't say what kind of failure they are.
+type StatusError struct {
+	Err    error
+	Status ExitStatus
+}
+
+func (se *StatusError) Error() string {
+	return se.Err.Error()
+}
+
+func (se *StatusError) Unwrap() error {
+	return se.Err
+}
+
+// withStatus gives err an exit status, unless it is nil.
+func withStatus(err error, status ExitStatus) error {
+	if err == nil {
+		return nil
+	}
+	return &StatusError{Err: err, Status: status}
+}
+
+// ParseError is returned when an input file can't be understood in any of
+// the formats stool knows.
+type ParseError struct {
+	Filename string
+	Format   Format
+	Err      error
+}
+
+func (pe *ParseError) Error() string {
+	if pe.Format == FormatUnknown {
+		return fmt.Sprintf(`could not parse %q: %s`, pe.Filename, pe.Err)
+	}
+	return fmt.Sprintf(`could not parse %q as %s: %s`, pe.Filename, pe.Format, pe.Err)
+}
+
+func (pe *ParseError) Unwrap() error {
+	return pe.Err
+}
+
+// QuerySyntaxError is returned when a search path can't be understood.
+// Offset counts runes from the start of Path.
+type QuerySyntaxError struct {
+	Path    string
+	Offset  int
+	Message string
+}
+
+func (qse *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("syntax error in search path at offset %d: %s\n%s", qse.Offset, qse.Message, caret(qse.Path, qse.Offset))
+}
+
+// UnknownFunctionError is returned when a search path calls a function that
+// doesn't exist. Offset counts runes from the start of Path.
+type UnknownFunctionError struct {
+	Name   string
+	Path   string
+	Offset int
+}
+
+func (ufe *UnknownFunctionError) Error() string {
+	msg := fmt.Sprintf(`unknown function "%s()" in search path at offset %d`, ufe.Name, ufe.Offset)
+	if suggestion := suggestKey(ufe.Name, FunctionNames); suggestion != `` {
+		msg += fmt.Sprintf(`; did you mean "%s()"?`, suggestion)
+	}
+	return fmt.Sprintf("%s\nthe functions are %s()\n%s", msg, strings.Join(FunctionNames, `(), `), caret(ufe.Path, ufe.Offset))
+}
+
+// TemplateError is returned when an output template can't be parsed or
+// rendered. Name is the template file, or "cmdline" for --template.
+type TemplateError struct {
+	Name string
+	Err  error
+}
+
+func (te *TemplateError) Error() string {
+	msg := te.Err.Error()
+	if strings.HasPrefix(msg, `template: `) {
+		return msg
+	}
+	return fmt.Sprintf(`template: %s: %s`, te.Name, msg)
+}
+
+func (te *TemplateError) Unwrap() error {
+	return te.Err
+}
+
+// caret renders a search path with a "^" under the rune at offset.
+func caret(path string, offset int) string {
+	runes := []rune(path)
+	if offset > len(runes) {
+		offset = len(runes)
+	}
+	indent := []rune(strings.Repeat(` `, offset))
+	for idx, r := range runes[:offset] {
+		if r == '\t' {
+			indent[idx] = '\t'
+		}
+	}
+	return fmt.Sprintf("    %s\n    %s^", path, string(indent))
+}
+
+// locateQueryError moves the offset of an error found while evaluating part
+// of a search path so that it counts from the start of the whole path.
+func locateQueryError(err error, path string, offset int) error {
+	var (
+		qse *QuerySyntaxError
+		ufe *UnknownFunctionError
+	)
+	switch {
+	case errors.As(err, &qse):
+		return &QuerySyntaxError{Path: path, Offset: qse.Offset + offset, Message: qse.Message}
+	case errors.As(err, &ufe):
+		return &UnknownFunctionError{Name: ufe.Name, Path: path, Offset: ufe.Offset + offset}
+	}
+	return err
+}
+
+// ErrorReport is how errors are written by --error-format json.
+type ErrorReport struct {
+	Kind       string `json:"kind"`
+	Message    string `json:"message"`
+	ExitStatus int    `json:"exit_status"`
+	Filename   string `json:"filename,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Offset     *int   `json:"offset,omitempty"`
+	Function   string `json:"function,omitempty"`
+}
+
+// NewErrorReport describes an error along with whatever details its type
+// carries.
+func NewErrorReport(err error, status ExitStatus) ErrorReport {
+	report := ErrorReport{
+		Kind:       `error`,
+		Message:    err.Error(),
+		ExitStatus: int(status),
+	}
+	var (
+		ue        *UsageError
+		pe        *ParseError
+		qse       *QuerySyntaxError
+		ufe       *UnknownFunctionError
+		te        *TemplateError
+		noResults *NoResultsError
+		pathErr   *fs.PathError
+	)
+	switch {
+	case errors.As(err, &ue):
+		report.Kind = `usage`
+	case errors.As(err, &pe):
+		report.Kind, report.Filename = `parse`, pe.Filename
+	case errors.As(err, &qse):
+		report.Kind, report.Path, report.Offset = `query_syntax`, qse.Path, &qse.Offset
+	case errors.As(err, &ufe):
+		report.Kind, report.Path, report.Offset, report.Function = `unknown_function`, ufe.Path, &ufe.Offset, ufe.Name
+	case errors.As(err, &te):
+		report.Kind, report.Filename = `template`, te.Name
+	case errors.As(err, &noResults):
+		report.Kind, report.Path, report.Offset = `no_results`, noResults.Path, &noResults.Offset
+	case errors.As(err, &pathErr):
+		report.Kind, report.Filename = `io`, pathErr.Path
+	}
+	return report
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ErrorTestCase struct {
+	Name           string
+	Err            error
+	ExpectedKind   string
+	ExpectedOffset int
+	ExpectedStatus ExitStatus
+}
+
+func (etc ErrorTestCase) Test(t *testing.T) {
+	t.Helper()
+	status := exitStatusOf(etc.Err, ExitFailure)
+	assert.Equal(t, etc.ExpectedStatus, status, etc.Name)
+	report := NewErrorReport(etc.Err, status)
+	assert.Equal(t, etc.ExpectedKind, report.Kind, etc.Name)
+	if report.Offset != nil {
+		assert.Equal(t, etc.ExpectedOffset, *report.Offset, etc.Name)
+	}
+}
+
+func evaluateError(path string) error {
+	_, err := Evaluate(Normalize(map[string]any{`a`: []any{map[string]any{`b`: 1}}}), path)
+	return err
+}
+
+func templateError(text string) error {
+	_, err := GetTemplate(text)
+	return err
+}
+
+var ErrorTestCases = []ErrorTestCase{
+	{
+		Name:           `parse`,
+		Err:            parseError(`test_data/malformed.yaml`),
+		ExpectedKind:   `parse`,
+		ExpectedStatus: ExitParse,
+	},
+	{
+		Name:           `missing file`,
+		Err:            parseError(`test_data/does_not_exist.yaml`),
+		ExpectedKind:   `io`,
+		ExpectedStatus: ExitIO,
+	},
+	{
+		Name:           `unknown function`,
+		Err:            evaluateError(`a.nothing()`),
+		ExpectedKind:   `unknown_function`,
+		ExpectedOffset: 2,
+		ExpectedStatus: ExitQuery,
+	},
+	{
+		Name:           `unknown function in brace`,
+		Err:            evaluateError(`a[ nothing() == 1 ]`),
+		ExpectedKind:   `unknown_function`,
+		ExpectedOffset: 3,
+		ExpectedStatus: ExitQuery,
+	},
+	{
+		Name:           `brace without comparison`,
+		Err:            evaluateError(`a[*][ b ]`),
+		ExpectedKind:   `query_syntax`,
+		ExpectedOffset: 6,
+		ExpectedStatus: ExitQuery,
+	},
+	{
+		Name:           `usage`,
+		Err:            &UsageError{Err: errors.New(`no files to merge`)},
+		ExpectedKind:   `usage`,
+		ExpectedStatus: ExitUsage,
+	},
+	{
+		Name:           `template`,
+		Err:            templateError(`{{ .x`),
+		ExpectedKind:   `template`,
+		ExpectedStatus: ExitTemplate,
+	},
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range ErrorTestCases {
+		tc.Test(t)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+//go:embed usage_fmt.txt
+var FmtUsage string
+
+// QuoteStyle determines how Reformat quotes strings in YAML.
+type QuoteStyle int
+
+const (
+	// QuoteKeep leaves each string quoted the way it was.
+	QuoteKeep QuoteStyle = iota
+	// QuotePlain only quotes strings that would mean something else, such
+	// as "true" or "123", without quotes.
+	QuotePlain
+	// QuoteSingle puts single quotes around every string value.
+	QuoteSingle
+	// QuoteDouble puts double quotes around every string value.
+	QuoteDouble
+)
+
+// LookupQuoteStyle interprets a user-supplied quote style name.
+func LookupQuoteStyle(name string) (QuoteStyle, error) {
+	switch strings.ToLower(name) {
+	case `keep`, `preserve`:
+		return QuoteKeep, nil
+	case `plain`, `minimal`, `none`:
+		return QuotePlain, nil
+	case `single`:
+		return QuoteSingle, nil
+	case `double`:
+		return QuoteDouble, nil
+	default:
+		return QuoteKeep, fmt.Errorf(`unknown quote style %q`, name)
+	}
+}
+
+// FmtOptions configures Reformat.
+type FmtOptions struct {
+	// Indent is the number of spaces for each level of nesting.
+	Indent   int
+	SortKeys bool
+	// Quotes applies to YAML; JSON strings are always double quoted.
+	Quotes       QuoteStyle
+	FinalNewline bool
+	// Compact writes JSON on a single line.
+	Compact bool
+}
+
+// DefaultFmtOptions indents by four spaces, the same as stool's other
+// output, and keeps keys in order and strings quoted as they were.
+var DefaultFmtOptions = FmtOptions{
+	Indent:       4,
+	Quotes:       QuoteKeep,
+	FinalNewline: true,
+}
+
+// Reformat lays out the source of a structured file in a consistent way.
+// YAML is reformatted from its node tree rather than the decoded values,
+// so that the order of keys and the comments are kept. JSON is copied
+// token by token, so that its numbers and strings keep their exact value.
+func Reformat(source []byte, format Format, opts FmtOptions) ([]byte, error) {
+	if opts.Indent < 1 {
+		return nil, fmt.Errorf(`the indent must be at least 1, not %d`, opts.Indent)
+	}
+	buff := new(bytes.Buffer)
+	switch format {
+	case FormatJSON:
+		if err := reformatJSON(buff, source, opts); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		if err := reformatYAML(buff, source, opts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf(`don't know how to format %s`, format)
+	}
+	out := bytes.TrimRight(buff.Bytes(), "\n")
+	if opts.FinalNewline && len(out) != 0 {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+func reformatYAML(buff *bytes.Buffer, source []byte, opts FmtOptions) error {
+	if opts.Indent < 2 {
+		return fmt.Errorf(`YAML can't be indented by less than 2 spaces, not %d`, opts.Indent)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(source))
+	enc := yaml.NewEncoder(buff)
+	enc.SetIndent(opts.Indent)
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		normalizeNode(&node, opts, false)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+func reformatJSON(buff *bytes.Buffer, source []byte, opts FmtOptions) error {
+	dec := json.NewDecoder(bytes.NewReader(source))
+	dec.UseNumber()
+	if !dec.More() {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if err := writeJSONValue(buff, dec, opts, 0); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf(`a JSON file can only hold one value`)
+	}
+	buff.WriteByte('\n')
+	return nil
+}
+
+// normalizeNode sorts keys and changes the quotes on strings throughout a
+// node tree.
+func normalizeNode(node *yaml.Node, opts FmtOptions, isKey bool) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			normalizeNode(child, opts, false)
+		}
+	case yaml.MappingNode:
+		if opts.SortKeys {
+			sortMappingNode(node)
+		}
+		for idx, child := range node.Content {
+			normalizeNode(child, opts, idx%2 == 0)
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() != `!!str` || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return
+		}
+		quotes := yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+		switch {
+		case opts.Quotes == QuotePlain:
+			if !needsQuotes(node.Value) {
+				node.Style &^= quotes
+			}
+		case isKey:
+		case opts.Quotes == QuoteSingle && !strings.Contains(node.Value, "\n"):
+			node.Style = node.Style&^quotes | yaml.SingleQuotedStyle
+		case opts.Quotes == QuoteDouble:
+			node.Style = node.Style&^quotes | yaml.DoubleQuotedStyle
+		}
+	}
+}
+
+// needsQuotes reports whether a string has to be quoted to be read back
+// as a string, including by older parsers that take "yes" and "off" to be
+// booleans.
+func needsQuotes(value string) bool {
+	out, err := yaml.Marshal(value)
+	return err != nil || len(out) == 0 || out[0] == '"' || out[0] == '\''
+}
+
+// sortMappingNode puts the key and value pairs of a mapping in order by
+// key. Comments stay with the pair they belong to.
+func sortMappingNode(node *yaml.Node) {
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[idx], node.Content[idx+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	for idx, pair := range pairs {
+		node.Content[2*idx], node.Content[2*idx+1] = pair[0], pair[1]
+	}
+}
+
+// writeJSONValue copies the next value from a JSON decoder. Numbers are
+// copied as they were written, so they keep their exact value.
+func writeJSONValue(buff *bytes.Buffer, dec *json.Decoder, opts FmtOptions, depth int) error {
+	newline := func(buff *bytes.Buffer, depth int) {
+		if !opts.Compact {
+			buff.WriteByte('\n')
+			buff.WriteString(strings.Repeat(` `, opts.Indent*depth))
+		}
+	}
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			if !dec.More() {
+				buff.WriteString(`[]`)
+				_, err := dec.Token()
+				return err
+			}
+			buff.WriteByte('[')
+			for idx := 0; dec.More(); idx++ {
+				if idx != 0 {
+					buff.WriteByte(',')
+				}
+				newline(buff, depth+1)
+				if err := writeJSONValue(buff, dec, opts, depth+1); err != nil {
+					return err
+				}
+			}
+			newline(buff, depth)
+			buff.WriteByte(']')
+			_, err := dec.Token()
+			return err
+		}
+		var pairs [][2]string
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			value := new(bytes.Buffer)
+			if err := writeJSONValue(value, dec, opts, depth+1); err != nil {
+				return err
+			}
+			pairs = append(pairs, [2]string{key.(string), value.String()})
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			buff.WriteString(`{}`)
+			return nil
+		}
+		if opts.SortKeys {
+			sort.SliceStable(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+		}
+		buff.WriteByte('{')
+		for idx, pair := range pairs {
+			if idx != 0 {
+				buff.WriteByte(',')
+			}
+			newline(buff, depth+1)
+			writeJSONString(buff, pair[0])
+			buff.WriteByte(':')
+			if !opts.Compact {
+				buff.WriteByte(' ')
+			}
+			buff.WriteString(pair[1])
+		}
+		newline(buff, depth)
+		buff.WriteByte('}')
+	case string:
+		writeJSONString(buff, t)
+	case json.Number:
+		buff.WriteString(t.String())
+	case nil:
+		buff.WriteString(`null`)
+	default:
+		fmt.Fprint(buff, t)
+	}
+	return nil
+}
+
+func writeJSONString(buff *bytes.Buffer, value string) {
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	buff.Truncate(buff.Len() - 1)
+}
+
+// fmtFile reformats one file. It reports whether the file was already
+// formatted, and writes it back in place unless check is set.
+func fmtFile(filename string, format Format, opts FmtOptions, check bool, out io.Writer) (bool, error) {
+	doc, err := ParseDocument(filename, format)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := Reformat(doc.Source, doc.Format, opts)
+	if err != nil {
+		return false, &ParseError{Filename: filename, Format: doc.Format, Err: err}
+	}
+	unchanged := bytes.Equal(formatted, doc.Source)
+	switch {
+	case filename == `-`:
+		if check {
+			return unchanged, nil
+		}
+		_, err = out.Write(formatted)
+	case check:
+		if !unchanged {
+			_, err = fmt.Fprintln(out, filename)
+		}
+	case !unchanged:
+		var info os.FileInfo
+		if info, err = os.Stat(filename); err == nil {
+			err = ioutil.WriteFile(filename, formatted, info.Mode())
+		}
+	}
+	return unchanged, err
+}
+
+func runFmt(args []string) error {
+	var (
+		inputFormat string
+		quotes      = `keep`
+		check       bool
+		opts        = DefaultFmtOptions
+	)
+	flags := newFlagSet(`fmt`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input files; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.BoolVar(&check, `check`, check, `list the files that aren't formatted instead of changing them`)
+	aliasFlag(flags, `check`, `c`)
+	flags.IntVar(&opts.Indent, `indent`, opts.Indent, `the number of spaces to indent each level`)
+	aliasFlag(flags, `indent`, `n`)
+	flags.BoolVar(&opts.SortKeys, `sort-keys`, opts.SortKeys, `put the keys of every map in order`)
+	aliasFlag(flags, `sort-keys`, `S`)
+	flags.StringVar(&quotes, `quotes`, quotes, `how to quote strings in YAML; keep|plain|single|double`)
+	aliasFlag(flags, `quotes`, `q`)
+	flags.BoolVar(&opts.Compact, `compact`, opts.Compact, `write JSON on a single line`)
+	flags.BoolVar(&opts.FinalNewline, `final-newline`, opts.FinalNewline, `end the file with a newline`)
+	if err := parseFlags(flags, args, FmtUsage); err != nil {
+		return err
+	}
+
+	var err error
+	opts.Quotes, err = LookupQuoteStyle(quotes)
+	if err != nil {
+		return &UsageError{Err: err}
+	}
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		filenames = []string{`-`}
+	}
+	var unformatted bool
+	for _, filename := range filenames {
+		formatted, err := fmtFile(filename, LookupFormat(inputFormat), opts, check, os.Stdout)
+		if err != nil {
+			return err
+		}
+		unformatted = unformatted || !formatted
+	}
+	if check && unformatted {
+		return ExitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type FmtTestCase struct {
+	Source   string
+	Format   Format
+	Options  FmtOptions
+	Expected string
+}
+
+func (ftc FmtTestCase) Test(t *testing.T) {
+	t.Helper()
+	out, err := Reformat([]byte(ftc.Source), ftc.Format, ftc.Options)
+	assert.NoError(t, err, ftc.Source)
+	assert.Equal(t, ftc.Expected, string(out), ftc.Source)
+
+	again, err := Reformat(out, ftc.Format, ftc.Options)
+	assert.NoError(t, err, ftc.Source)
+	assert.Equal(t, string(out), string(again), `formatting twice changed the output`)
+}
+
+var FmtTestCases = []FmtTestCase{
+	{
+		Source:   "b: 1\na:\n  - x\n  - y\n\n\n",
+		Format:   FormatYAML,
+		Options:  DefaultFmtOptions,
+		Expected: "b: 1\na:\n    - x\n    - y\n",
+	},
+	{
+		Source: "# about b\nb: 1 # one\n\n# about a\na: {y: 2, x: 1}\n",
+		Format: FormatYAML,
+		Options: FmtOptions{
+			Indent:       2,
+			SortKeys:     true,
+			FinalNewline: true,
+		},
+		Expected: "# about a\na: {x: 1, y: 2}\n# about b\nb: 1 # one\n",
+	},
+	{
+		Source: "- \"x\"\n- 'yes'\n- \"12\"\n- |\n  block\n",
+		Format: FormatYAML,
+		Options: FmtOptions{
+			Indent:       2,
+			Quotes:       QuotePlain,
+			FinalNewline: true,
+		},
+		Expected: "- x\n- 'yes'\n- \"12\"\n- |\n  block\n",
+	},
+	{
+		Source: "key: value\nn: 1\n",
+		Format: FormatYAML,
+		Options: FmtOptions{
+			Indent: 2,
+			Quotes: QuoteDouble,
+		},
+		Expected: "key: \"value\"\nn: 1",
+	},
+	{
+		Source:   `{"b": [1.50, true, null], "a": {}, "id": 12345678901234567890, "html": "<b>"}`,
+		Format:   FormatJSON,
+		Options:  DefaultFmtOptions,
+		Expected: "{\n    \"b\": [\n        1.50,\n        true,\n        null\n    ],\n    \"a\": {},\n    \"id\": 12345678901234567890,\n    \"html\": \"<b>\"\n}\n",
+	},
+	{
+		Source: "{\n\t\"b\": 1,\n\t\"a\": [\"x\"]\n}",
+		Format: FormatJSON,
+		Options: FmtOptions{
+			Indent:       2,
+			SortKeys:     true,
+			Compact:      true,
+			FinalNewline: true,
+		},
+		Expected: "{\"a\":[\"x\"],\"b\":1}\n",
+	},
+	{
+		Source:   `{"a\/b": "\u00e9\t", "n": -0.0, "e": 1E400}`,
+		Format:   FormatJSON,
+		Options:  FmtOptions{Indent: 2, Compact: true},
+		Expected: `{"a/b":"é\t","n":-0.0,"e":1E400}`,
+	},
+	{
+		Source:   `{"` + strings.Repeat(`k`, 2000) + `": [[], {}]}`,
+		Format:   FormatJSON,
+		Options:  FmtOptions{Indent: 2, Compact: true},
+		Expected: `{"` + strings.Repeat(`k`, 2000) + `":[[],{}]}`,
+	},
+}
+
+func TestReformat(t *testing.T) {
+	for _, tc := range FmtTestCases {
+		tc.Test(t)
+	}
+	_, err := Reformat([]byte(`{"a": 1} {"b": 2}`), FormatJSON, DefaultFmtOptions)
+	assert.Error(t, err)
+	_, err = Reformat([]byte(`{"a": 1`), FormatJSON, DefaultFmtOptions)
+	assert.Error(t, err)
+	out, err := Reformat([]byte(" \n"), FormatJSON, DefaultFmtOptions)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+	_, err = Reformat([]byte(`a: 1`), FormatYAML, FmtOptions{})
+	assert.Error(t, err)
+	_, err = Reformat([]byte(`a: 1`), FormatYAML, FmtOptions{Indent: 1})
+	assert.ErrorContains(t, err, `less than 2 spaces`)
+	out, err = Reformat([]byte(`[1]`), FormatJSON, FmtOptions{Indent: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n 1\n]", string(out))
+}
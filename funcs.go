@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// query is the query template function. It searches data with a
+// search path, the same way --search does, and returns the results.
+//
+//	{{ range query . "contacts[*].name" }}{{ . }}{{ end }}
+func (tv *templateValues) query(data any, path string) ([]any, error) {
+	results, err := Evaluate(tv.Normalize(data), path)
+	if err != nil {
+		return nil, err
+	}
+	return tv.Value(results).([]any), nil
+}
+
+// fromYAML is the fromYaml template function. It parses a string
+// of YAML into the same kinds of values a template is given.
+func (tv *templateValues) fromYAML(text string) (any, error) {
+	value, err := decodeYAML([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf(`could not parse YAML: %w`, err)
+	}
+	return tv.Value(value), nil
+}
+
+// fromJSON is the fromJson template function. It parses a string
+// of JSON into the same kinds of values a template is given.
+func (tv *templateValues) fromJSON(text string) (any, error) {
+	value, err := decodeJSON([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf(`could not parse JSON: %w`, err)
+	}
+	return tv.Value(value), nil
+}
+
+// templateToTOML is the toToml template function. TOML documents are
+// tables, so the value has to be a map. TOML has no null, so members that
+// are null are left out.
+func templateToTOML(value any) (string, error) {
+	m, ok := Normalize(value).(*Map)
+	if !ok {
+		return ``, fmt.Errorf(`toToml needs a map, not %s`, valueKind(value))
+	}
+	table, err := tomlValue(m, nil)
+	if err != nil {
+		return ``, err
+	}
+	buff := new(bytes.Buffer)
+	if err := toml.NewEncoder(buff).Encode(table); err != nil {
+		return ``, fmt.Errorf(`could not render TOML: %w`, err)
+	}
+	return buff.String(), nil
+}
+
+// tomlValue converts a value into the plain Go values the TOML encoder
+// takes. TOML numbers are int64s and float64s, so a number that neither can
+// hold is an error rather than being rounded. A float64 holds a number if
+// it is written back with the same value, as 0.1 is.
+func tomlValue(value any, loc Location) (any, error) {
+	switch v := value.(type) {
+	case *Map:
+		out := make(map[string]any, v.Len())
+		for _, key := range v.keys {
+			item, err := tomlValue(v.values[key], append(loc[:len(loc):len(loc)], key))
+			if err != nil {
+				return nil, err
+			}
+			out[key] = item
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			var err error
+			if out[idx], err = tomlValue(item, append(loc[:len(loc):len(loc)], idx)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil && scalarsEqual(json.Number(strconv.FormatFloat(f, 'g', -1, 64)), v) {
+			return f, nil
+		}
+		return nil, fmt.Errorf(`toToml can't write %s at %s exactly, because TOML numbers are 64 bits`, v, loc)
+	default:
+		return value, nil
+	}
+}
+
+// toCSV is the toCsv template function. It takes a list of rows.
+// If the rows are maps, the first line names their keys, in the order they
+// were first seen; otherwise each row is a list of cells, or a single cell.
+// Cells that are maps or lists are written as JSON.
+func (tv *templateValues) toCSV(value any) (string, error) {
+	rows, ok := tv.Normalize(value).([]any)
+	if !ok {
+		return ``, fmt.Errorf(`toCsv needs a list of rows, not %s`, valueKind(value))
+	}
+	var (
+		header []string
+		seen   = make(map[string]bool)
+	)
+	for _, row := range rows {
+		m, ok := row.(*Map)
+		if !ok {
+			continue
+		}
+		for _, key := range m.Keys() {
+			if !seen[key] {
+				seen[key] = true
+				header = append(header, key)
+			}
+		}
+	}
+	buff := new(strings.Builder)
+	w := csv.NewWriter(buff)
+	if header != nil {
+		if err := w.Write(header); err != nil {
+			return ``, err
+		}
+	}
+	for idx, row := range rows {
+		var values []any
+		switch r := row.(type) {
+		case *Map:
+			for _, key := range header {
+				value, _ := r.Get(key)
+				values = append(values, value)
+			}
+		case []any:
+			if header != nil {
+				return ``, fmt.Errorf(`toCsv: row %d is a list, but the other rows are maps`, idx)
+			}
+			values = r
+		default:
+			if header != nil {
+				return ``, fmt.Errorf(`toCsv: row %d is %s, but the other rows are maps`, idx, valueKind(row))
+			}
+			values = []any{r}
+		}
+		record := make([]string, len(values))
+		for col, value := range values {
+			cell, err := csvCell(value)
+			if err != nil {
+				return ``, err
+			}
+			record[col] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return ``, err
+		}
+	}
+	w.Flush()
+	return buff.String(), w.Error()
+}
+
+// valueKind describes what sort of value something is, for errors.
+func valueKind(value any) string {
+	switch Normalize(value).(type) {
+	case nil:
+		return `null`
+	case *Map:
+		return `a map`
+	case []any:
+		return `a list`
+	case string:
+		return `a string`
+	case bool:
+		return `a boolean`
+	default:
+		return `a number`
+	}
+}
+
+func csvCell(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return ``, nil
+	case string:
+		return v, nil
+	case *Map, []any:
+		out, err := OutputStyle.JSON(v, false)
+		return string(out), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type FuncTestCase struct {
+	Template      string
+	Expected      string
+	ExpectedError string
+}
+
+func (ftc FuncTestCase) Test(t *testing.T, data any) {
+	t.Helper()
+	tmplt, err := Layout{Mode: TemplateAll}.Parse(`cmdline`, ftc.Template)
+	if !assert.NoError(t, err, ftc.Template) {
+		return
+	}
+	buff := new(bytes.Buffer)
+	err = tmplt.Execute(buff, data)
+	if ftc.ExpectedError != `` {
+		assert.ErrorContains(t, err, ftc.ExpectedError, ftc.Template)
+		return
+	}
+	assert.NoError(t, err, ftc.Template)
+	assert.Equal(t, ftc.Expected, buff.String(), ftc.Template)
+}
+
+var FuncTestCases = []FuncTestCase{
+	{
+		Template: `{{ query . "animals.vertebrates.mammals[*]" | join "," }}`,
+		Expected: `horse,shrew,cat`,
+	},
+	{
+		Template: `{{ range query . "minerals[*][. == \"slate\"]^.path()" }}{{ . }}{{ end }}`,
+		Expected: `minerals.metamorphic`,
+	},
+	{
+		Template: `{{ (index (query . "animals.invertebrates") 0).mollusks | json }}`,
+		Expected: `["clam"]`,
+	},
+	{
+		Template:      `{{ query . "animals.lenght()" }}`,
+		ExpectedError: `did you mean "length()"?`,
+	},
+	{
+		Template: `{{ $doc := fromYaml "a: [1.50, 0x10]\nb: {c: yes}" }}{{ $doc | json }} {{ $doc.b.c }}`,
+		Expected: `{"a":[1.50,16],"b":{"c":"yes"}} yes`,
+	},
+	{
+		Template: `{{ $doc := fromJson "{\"id\": 12345678901234567890}" }}{{ $doc.id }} {{ query $doc "id" | json }}`,
+		Expected: `12345678901234567890 [12345678901234567890]`,
+	},
+	{
+		Template:      `{{ fromJson "{" }}`,
+		ExpectedError: `could not parse JSON`,
+	},
+	{
+		Template: `{{ fromJson "{\"name\": \"x\", \"skip\": null, \"n\": 1.50, \"tags\": [\"a\"], \"sub\": {\"on\": true}}" | toToml }}`,
+		Expected: "n = 1.5\nname = \"x\"\ntags = [\"a\"]\n\n[sub]\n  on = true\n",
+	},
+	{
+		Template:      `{{ fromJson "{\"a\": {\"b\": [1, 12345678901234567890]}}" | toToml }}`,
+		ExpectedError: `toToml can't write 12345678901234567890 at a.b[1] exactly, because TOML numbers are 64 bits`,
+	},
+	{
+		Template: `{{ fromJson "{\"big\": 9223372036854775807, \"f\": 0.1}" | toToml }}`,
+		Expected: "big = 9223372036854775807\nf = 0.1\n",
+	},
+	{
+		Template:      `{{ toToml (list 1 2) }}`,
+		ExpectedError: `toToml needs a map, not a list`,
+	},
+	{
+		Template: `{{ fromJson "[{\"name\": \"Bob\", \"tags\": [\"a\", \"b\"]}, {\"id\": 2, \"name\": \"Al, Jr.\"}]" | toCsv }}`,
+		Expected: "name,tags,id\nBob,\"[\"\"a\"\",\"\"b\"\"]\",\n\"Al, Jr.\",,2\n",
+	},
+	{
+		Template: `{{ toCsv (list (list 1 "x") (list 2 nil) "z") }}`,
+		Expected: "1,x\n2,\nz\n",
+	},
+	{
+		Template:      `{{ fromJson "[{\"a\": 1}, [2]]" | toCsv }}`,
+		ExpectedError: `row 1 is a list, but the other rows are maps`,
+	},
+	{
+		Template:      `{{ toCsv "x" }}`,
+		ExpectedError: `toCsv needs a list of rows, not a string`,
+	},
+}
+
+func TestFuncs(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	for _, tc := range FuncTestCases {
+		tc.Test(t, new(templateValues).Value(doc.Data))
+	}
+}
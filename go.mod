@@ -3,9 +3,12 @@ module github.com/Unquabain/stool
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/masterminds/sprig v2.22.0+incompatible
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.0
 	github.com/stretchr/testify v1.8.0
 	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e
+	golang.org/x/term v0.0.0-20220526004731-065cf7ba2467
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -20,4 +23,5 @@ require (
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.0.0-20220722155217-630584e8d5aa // indirect
+	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
 )
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Location is the concrete route to a value within a document: a string for
+// each map member and an int for each array index. Values made by a search
+// path function, like keys(), have a FunctionKey after the route to the
+// value they were made from.
+type Location []any
+
+// FunctionKey is the part of a Location for a value made by a search path
+// function, rather than found in the document. It is the function's name.
+type FunctionKey string
+
+// Derived reports whether the value isn't in the document itself, but was
+// made by a function, so it has no position and can't be changed.
+func (l Location) Derived() bool {
+	for _, key := range l {
+		if _, ok := key.(FunctionKey); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Child returns a new Location one level below this one.
+func (l Location) Child(key any) Location {
+	child := make(Location, len(l), len(l)+1)
+	copy(child, l)
+	return append(child, key)
+}
+
+// String renders the Location as a stool search path, e.g.
+// animals.vertebrates.mammals[2]. The root of the document is ".".
+func (l Location) String() string {
+	if len(l) == 0 {
+		return `.`
+	}
+	sb := new(strings.Builder)
+	for _, key := range l {
+		switch k := key.(type) {
+		case int:
+			fmt.Fprintf(sb, `[%d]`, k)
+		case FunctionKey:
+			if sb.Len() != 0 {
+				sb.WriteRune('.')
+			}
+			sb.WriteString(string(k) + `()`)
+		default:
+			member := fmt.Sprint(k)
+			if !isPlainMember(member) {
+				sb.WriteString(quoteMember(member))
+				continue
+			}
+			if sb.Len() != 0 {
+				sb.WriteRune('.')
+			}
+			sb.WriteString(member)
+		}
+	}
+	return sb.String()
+}
+
+// Pointer renders the Location as an RFC 6901 JSON Pointer.
+func (l Location) Pointer() string {
+	sb := new(strings.Builder)
+	for _, key := range l {
+		sb.WriteRune('/')
+		member := fmt.Sprint(key)
+		member = strings.ReplaceAll(member, `~`, `~0`)
+		member = strings.ReplaceAll(member, `/`, `~1`)
+		sb.WriteString(member)
+	}
+	return sb.String()
+}
+
+// isPlainMember reports whether a member name can be written in a search
+// path without brackets.
+func isPlainMember(member string) bool {
+	mlen, allDigits := scanMember([]rune(member))
+	return member != `` && !allDigits && mlen == len([]rune(member))
+}
+
+func quoteMember(member string) string {
+	if strings.ContainsRune(member, '"') {
+		return `['` + member + `']`
+	}
+	return `["` + member + `"]`
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+//go:embed usage_merge.txt
+var MergeUsage string
+
+// ArrayStrategy determines how Merge combines two arrays found at the same
+// place in two documents.
+type ArrayStrategy int
+
+const (
+	// ArrayReplace discards the base array in favor of the overlay.
+	ArrayReplace ArrayStrategy = iota
+	// ArrayAppend concatenates the overlay onto the end of the base.
+	ArrayAppend
+	// ArrayMergeByKey merges map elements that share the same value for
+	// MergeOptions.Key, and appends everything else.
+	ArrayMergeByKey
+)
+
+// LookupArrayStrategy interprets a user-supplied array strategy name.
+func LookupArrayStrategy(name string) (ArrayStrategy, error) {
+	switch strings.ToLower(name) {
+	case `replace`:
+		return ArrayReplace, nil
+	case `append`, `concat`:
+		return ArrayAppend, nil
+	case `merge`, `key`, `merge-by-key`:
+		return ArrayMergeByKey, nil
+	default:
+		return ArrayReplace, fmt.Errorf(`unknown array strategy %q`, name)
+	}
+}
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	Arrays ArrayStrategy
+	Key    string
+}
+
+// DefaultMergeOptions replaces arrays, and uses "name" as the key if the
+// strategy is changed to ArrayMergeByKey.
+var DefaultMergeOptions = MergeOptions{
+	Arrays: ArrayReplace,
+	Key:    `name`,
+}
+
+// Merge deep-merges overlay on top of base and returns the result. Maps are
+// merged recursively, a null in an overlay map deletes the key from the
+// base, arrays are combined according to the options, and anything else in
+// the overlay replaces what's in the base. Neither argument is modified.
+func Merge(base, overlay any, opts MergeOptions) any {
+	switch ov := overlay.(type) {
+	case *Map:
+		if bv, ok := base.(*Map); ok {
+			return mergeMaps(bv, ov, opts)
+		}
+	case []any:
+		if bv, ok := base.([]any); ok {
+			return mergeArrays(bv, ov, opts)
+		}
+	}
+	return deleteNulls(overlay)
+}
+
+// mergeMaps keeps the base's keys in their order, followed by any keys that
+// only the overlay has.
+func mergeMaps(base, overlay *Map, opts MergeOptions) *Map {
+	out := NewMap()
+	for _, k := range base.Keys() {
+		v, _ := base.Get(k)
+		out.Set(k, v)
+	}
+	for _, k := range overlay.Keys() {
+		v, _ := overlay.Get(k)
+		if v == nil {
+			out.Delete(k)
+			continue
+		}
+		if bv, ok := out.Get(k); ok {
+			out.Set(k, Merge(bv, v, opts))
+			continue
+		}
+		out.Set(k, deleteNulls(v))
+	}
+	return out
+}
+
+func mergeArrays(base, overlay []any, opts MergeOptions) []any {
+	switch opts.Arrays {
+	case ArrayAppend:
+		out := make([]any, 0, len(base)+len(overlay))
+		out = append(out, base...)
+		for _, v := range overlay {
+			out = append(out, deleteNulls(v))
+		}
+		return out
+	case ArrayMergeByKey:
+		out := make([]any, len(base), len(base)+len(overlay))
+		copy(out, base)
+	overlayloop:
+		for _, ov := range overlay {
+			okey, ok := lookupMergeKey(ov, opts.Key)
+			if ok {
+				for idx, bv := range out {
+					bkey, ok := lookupMergeKey(bv, opts.Key)
+					if ok && scalarsEqual(okey, bkey) {
+						out[idx] = Merge(bv, ov, opts)
+						continue overlayloop
+					}
+				}
+			}
+			out = append(out, deleteNulls(ov))
+		}
+		return out
+	default:
+		return deleteNulls(overlay).([]any)
+	}
+}
+
+func lookupMergeKey(item any, key string) (any, bool) {
+	dict, ok := item.(*Map)
+	if !ok {
+		return nil, false
+	}
+	value, ok := dict.Get(key)
+	return value, ok && value != nil
+}
+
+// deleteNulls copies a value from an overlay, dropping the keys of any maps
+// that are set to null, as they would be if they were merged onto a base.
+func deleteNulls(value any) any {
+	switch v := value.(type) {
+	case *Map:
+		return mergeMaps(NewMap(), v, DefaultMergeOptions)
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = deleteNulls(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// MergeDocuments merges each document on top of the ones before it. Empty
+// documents are skipped.
+func MergeDocuments(docs []*Document, opts MergeOptions) any {
+	var merged any
+	for _, doc := range docs {
+		if doc.Data == nil {
+			continue
+		}
+		if merged == nil {
+			merged = deleteNulls(doc.Data)
+			continue
+		}
+		merged = Merge(merged, doc.Data, opts)
+	}
+	return merged
+}
+
+func runMerge(args []string) error {
+	var (
+		inputFormat  string
+		outputFormat string
+		outputFile   = `-`
+		arrays       = `replace`
+		opts         = DefaultMergeOptions
+	)
+	flags := newFlagSet(`merge`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input files; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.StringVar(&outputFormat, `output-format`, outputFormat, `the format to write; defaults to the format of the first input`)
+	aliasFlag(flags, `output-format`, `F`)
+	flags.StringVar(&outputFile, `out`, outputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(flags, `out`, `o`)
+	flags.StringVar(&arrays, `arrays`, arrays, `how to merge arrays; replace|append|merge`)
+	aliasFlag(flags, `arrays`, `a`)
+	flags.StringVar(&opts.Key, `key`, opts.Key, `the member that identifies array items when merging arrays by key`)
+	aliasFlag(flags, `key`, `k`)
+	defineStyleFlags(flags)
+	if err := parseFlags(flags, args, MergeUsage); err != nil {
+		return err
+	}
+
+	if err := OutputStyle.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+
+	var err error
+	opts.Arrays, err = LookupArrayStrategy(arrays)
+	if err != nil {
+		return &UsageError{Err: err}
+	}
+	if flags.NArg() == 0 {
+		return &UsageError{Err: fmt.Errorf(`no files to merge`)}
+	}
+	docs := make([]*Document, 0, flags.NArg())
+	for _, filename := range flags.Args() {
+		doc, err := ParseDocument(filename, LookupFormat(inputFormat))
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	format := LookupFormat(outputFormat)
+	if format == FormatUnknown {
+		format = docs[0].Format
+	}
+	out, err := Marshal(MergeDocuments(docs, opts), format)
+	if err != nil {
+		return fmt.Errorf(`could not render merged document: %w`, err)
+	}
+	if outputFile == `-` {
+		fmt.Printf(`%s`, string(out))
+		return nil
+	}
+	return ioutil.WriteFile(outputFile, out, 0644)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MergeTestCase struct {
+	Files           []string
+	Arrays          ArrayStrategy
+	Path            string
+	ExpectedResults []any
+}
+
+func (mtc MergeTestCase) Test(t *testing.T) {
+	t.Helper()
+	docs := make([]*Document, 0, len(mtc.Files))
+	for _, filename := range mtc.Files {
+		doc, err := ParseDocument(filename, FormatUnknown)
+		assert.NoError(t, err, filename)
+		docs = append(docs, doc)
+	}
+	opts := DefaultMergeOptions
+	opts.Arrays = mtc.Arrays
+	merged := MergeDocuments(docs, opts)
+
+	results, err := Evaluate(merged, mtc.Path)
+	assert.NoError(t, err, mtc.Path)
+	assert.Equal(t, mtc.ExpectedResults, Plain(results), mtc.Path)
+}
+
+var MergeTestCases = []MergeTestCase{
+	{
+		Files: []string{`test_data/test.yaml`, `test_data/test.json`},
+		Path:  `minerals.igneous`,
+		ExpectedResults: []any{
+			[]any{`obsidian`, `granite`, `basalt`},
+		},
+	},
+	{
+		Files: []string{`test_data/test.yaml`, `test_data/overlay.yaml`},
+		Path:  `animals.vertebrates`,
+		ExpectedResults: []any{
+			map[string]any{
+				`mammals`:  []any{`whale`},
+				`reptiles`: []any{`lizard`, `snake`, `newt`},
+				`birds`:    []any{`robin`, `crow`},
+			},
+		},
+	},
+	{
+		Files:           []string{`test_data/test.yaml`, `test_data/overlay.yaml`},
+		Path:            `animals.keys()`,
+		ExpectedResults: []any{[]any{`vertebrates`}},
+	},
+	{
+		Files:           []string{`test_data/test.yaml`, `test_data/overlay.yaml`},
+		Path:            `minerals.metamorphic`,
+		ExpectedResults: []any{},
+	},
+	{
+		Files:  []string{`test_data/test.json`, `test_data/overlay.yaml`},
+		Arrays: ArrayAppend,
+		Path:   `vegetables.flowers`,
+		ExpectedResults: []any{
+			[]any{`rose`, `magnolia`, `tulip`, `narcissus`, `daisy`},
+		},
+	},
+	{
+		Files:  []string{`test_data/contacts.yaml`, `test_data/contacts_overlay.json`},
+		Arrays: ArrayMergeByKey,
+		Path:   `contacts[*].zip_code`,
+		ExpectedResults: []any{
+			`90210`, `10002`, `60601`,
+		},
+	},
+	{
+		Files:  []string{`test_data/contacts.yaml`, `test_data/contacts_overlay.json`},
+		Arrays: ArrayMergeByKey,
+		Path:   `contacts[name == "Bob"].phones[0].number`,
+		ExpectedResults: []any{
+			`555-0200`,
+		},
+	},
+	{
+		Files:  []string{`test_data/contacts.yaml`, `test_data/contacts_overlay.json`},
+		Arrays: ArrayReplace,
+		Path:   `contacts[*].name`,
+		ExpectedResults: []any{
+			`Bob`, `Carol`,
+		},
+	},
+}
+
+func TestMerge(t *testing.T) {
+	for _, tc := range MergeTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestLookupArrayStrategy(t *testing.T) {
+	for name, expected := range map[string]ArrayStrategy{
+		`replace`: ArrayReplace,
+		`APPEND`:  ArrayAppend,
+		`merge`:   ArrayMergeByKey,
+	} {
+		strategy, err := LookupArrayStrategy(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, strategy, name)
+	}
+	_, err := LookupArrayStrategy(`shuffle`)
+	assert.ErrorContains(t, err, `unknown array strategy`)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// asRat converts any of the numeric types a document can hold into an
+// exact rational number.
+func asRat(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v)), true
+	case float64:
+		if r := new(big.Rat); r.SetFloat64(v) != nil {
+			return r, true
+		}
+		return nil, false
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	default:
+		return nil, false
+	}
+}
+
+// compareNumber compares a number with the number written in a search
+// path without converting either of them to a float64.
+func compareNumber(lval any, rval string, comparison string) bool {
+	lv, ok := asRat(lval)
+	if !ok {
+		return false
+	}
+	rv, ok := new(big.Rat).SetString(strings.TrimSpace(rval))
+	if !ok {
+		return false
+	}
+	return typedCompare(lv.Cmp(rv), 0, comparison)
+}
+
+// templateNumber converts a json.Number into a float64 for templates,
+// whose comparisons and sprig's maths only work with Go's own numbers. It
+// is only converted if the float64 is written the same way, so that
+// templates write numbers exactly as they were written in the document;
+// 1.5 is converted, but 1.50, 1e2 and -0.0 stay json.Numbers.
+func templateNumber(n json.Number) any {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || strconv.FormatFloat(f, 'g', -1, 64) != string(n) {
+		return n
+	}
+	return f
+}
+
+// yamlNumber writes a json.Number into YAML exactly as it was written in
+// the JSON it came from. It has no tag, because an explicit !!int or
+// !!float can't be decoded if the number is too big for Go's own types.
+// fromYAMLNode reads it back as a number either way.
+type yamlNumber json.Number
+
+func (n yamlNumber) MarshalYAML() (any, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: string(n)}, nil
+}
+
+// yamlNumbers makes a copy of a value with every json.Number replaced with
+// a yamlNumber, because the YAML encoder would write them as strings. Maps
+// do the same for their own values when they are written.
+func yamlNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		return yamlNumber(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = yamlNumbers(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = yamlNumbers(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const numberTestSource = `{
+	"id": 1234567890123456789,
+	"big": 12345678901234567890,
+	"price": 1.50,
+	"items": [{"id": 9007199254740993}, {"id": 9007199254740992}]
+}`
+
+type NumberTestCase struct {
+	Path         string
+	ExpectedJSON string
+	ExpectedYAML string
+}
+
+func (ntc NumberTestCase) Test(t *testing.T) {
+	t.Helper()
+	data, err := decodeJSON([]byte(numberTestSource))
+	assert.NoError(t, err)
+	results, err := Evaluate(data, ntc.Path)
+	assert.NoError(t, err, ntc.Path)
+	out, err := DefaultStyle.JSON(results, false)
+	assert.NoError(t, err, ntc.Path)
+	assert.Equal(t, ntc.ExpectedJSON, string(out), ntc.Path)
+	out, err = DefaultStyle.YAML(results)
+	assert.NoError(t, err, ntc.Path)
+	assert.Equal(t, ntc.ExpectedYAML, string(out), ntc.Path)
+}
+
+var NumberTestCases = []NumberTestCase{
+	{
+		Path:         `id`,
+		ExpectedJSON: `[1234567890123456789]`,
+		ExpectedYAML: "- 1234567890123456789\n",
+	},
+	{
+		Path:         `big`,
+		ExpectedJSON: `[12345678901234567890]`,
+		ExpectedYAML: "- 12345678901234567890\n",
+	},
+	{
+		Path:         `price`,
+		ExpectedJSON: `[1.50]`,
+		ExpectedYAML: "- 1.50\n",
+	},
+	{
+		Path:         `items[id == 9007199254740993].id`,
+		ExpectedJSON: `[9007199254740993]`,
+		ExpectedYAML: "- 9007199254740993\n",
+	},
+	{
+		Path:         `items[id < 9007199254740993].id`,
+		ExpectedJSON: `[9007199254740992]`,
+		ExpectedYAML: "- 9007199254740992\n",
+	},
+	{
+		Path:         `items[id > 9007199254740992.5].id`,
+		ExpectedJSON: `[9007199254740993]`,
+		ExpectedYAML: "- 9007199254740993\n",
+	},
+}
+
+func TestNumbers(t *testing.T) {
+	for _, tc := range NumberTestCases {
+		tc.Test(t)
+	}
+	_, err := decodeJSON([]byte(`{} {}`))
+	assert.Error(t, err)
+	assert.True(t, scalarsEqual(json.Number(`2.0`), 2))
+
+	roundTrip := `{"huge":123456789012345678901234567890,"tiny":-98765432109876543210,"far":1e400}`
+	data, err := decodeJSON([]byte(roundTrip))
+	assert.NoError(t, err)
+	yamlOut, err := DefaultStyle.YAML(data)
+	assert.NoError(t, err)
+	data, err = decodeYAML(yamlOut)
+	assert.NoError(t, err, string(yamlOut))
+	jsonOut, err := DefaultStyle.JSON(data, false)
+	assert.NoError(t, err)
+	assert.Equal(t, roundTrip, string(jsonOut))
+	assert.False(t, scalarsEqual(json.Number(`9007199254740993`), float64(9007199254740992)))
+}
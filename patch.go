@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchError reports which operation of a JSON Patch could not be applied.
+type PatchError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (pe *PatchError) Error() string {
+	return fmt.Sprintf(`patch operation %d (%s %q): %s`, pe.Index, pe.Op, pe.Path, pe.Err)
+}
+
+func (pe *PatchError) Unwrap() error {
+	return pe.Err
+}
+
+// ApplyPatch applies a patch to a document and returns the result. A patch
+// that is an array is treated as an RFC 6902 JSON Patch, and anything else
+// as an RFC 7386 JSON Merge Patch.
+func ApplyPatch(data, patch any) (any, error) {
+	if ops, ok := patch.([]any); ok {
+		return ApplyJSONPatch(data, ops)
+	}
+	return ApplyMergePatch(data, patch), nil
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch. It is like a Merge
+// that replaces arrays, except that only maps in the patch are merged, so
+// nulls are only removed from them. Anything else, like an array, is put in
+// as it is.
+func ApplyMergePatch(data, patch any) any {
+	p, ok := patch.(*Map)
+	if !ok {
+		return copyValue(patch)
+	}
+	out := NewMap()
+	if target, ok := data.(*Map); ok {
+		for _, key := range target.keys {
+			out.Set(key, target.values[key])
+		}
+	}
+	for _, key := range p.keys {
+		value := p.values[key]
+		if value == nil {
+			out.Delete(key)
+			continue
+		}
+		existing, _ := out.Get(key)
+		out.Set(key, ApplyMergePatch(existing, value))
+	}
+	return out
+}
+
+// ApplyJSONPatch applies each operation of an RFC 6902 JSON Patch in order.
+// The document passed in is not modified.
+func ApplyJSONPatch(data any, ops []any) (any, error) {
+	data = copyValue(data)
+	for idx, item := range ops {
+		op, ok := item.(*Map)
+		if !ok {
+			return nil, &PatchError{Index: idx, Err: fmt.Errorf(`operation is not an object`)}
+		}
+		var err error
+		data, err = applyPatchOperation(data, op)
+		if err != nil {
+			name, _ := op.values[`op`].(string)
+			path, _ := op.values[`path`].(string)
+			return nil, &PatchError{Index: idx, Op: name, Path: path, Err: err}
+		}
+	}
+	return data, nil
+}
+
+func applyPatchOperation(data any, op *Map) (any, error) {
+	name, ok := op.values[`op`].(string)
+	if !ok {
+		return nil, fmt.Errorf(`missing "op"`)
+	}
+	path, err := patchPointer(op, `path`)
+	if err != nil {
+		return nil, err
+	}
+	value, hasValue := op.Get(`value`)
+	switch name {
+	case `add`, `replace`, `test`:
+		if !hasValue {
+			return nil, fmt.Errorf(`missing "value"`)
+		}
+	}
+	switch name {
+	case `add`:
+		return patchAdd(data, path, copyValue(value))
+	case `remove`:
+		return patchRemove(data, path)
+	case `replace`:
+		return patchReplace(data, path, copyValue(value))
+	case `move`:
+		from, err := patchPointer(op, `from`)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && strings.Join(path[:len(from)], `/`) == strings.Join(from, `/`) {
+			return nil, fmt.Errorf(`cannot move %q into one of its own children`, joinPointer(from))
+		}
+		value, err := patchGet(data, from)
+		if err != nil {
+			return nil, fmt.Errorf(`from: %w`, err)
+		}
+		data, err = patchRemove(data, from)
+		if err != nil {
+			return nil, fmt.Errorf(`from: %w`, err)
+		}
+		return patchAdd(data, path, value)
+	case `copy`:
+		from, err := patchPointer(op, `from`)
+		if err != nil {
+			return nil, err
+		}
+		value, err := patchGet(data, from)
+		if err != nil {
+			return nil, fmt.Errorf(`from: %w`, err)
+		}
+		return patchAdd(data, path, copyValue(value))
+	case `test`:
+		actual, err := patchGet(data, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(Diff(actual, value)) != 0 {
+			return nil, fmt.Errorf(`test failed: value is %s, not %s`, compactJSON(actual), compactJSON(value))
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf(`unknown operation %q`, name)
+	}
+}
+
+// patchPointer splits a JSON Pointer member of an operation into its
+// unescaped reference tokens.
+func patchPointer(op *Map, member string) ([]string, error) {
+	value, ok := op.Get(member)
+	if !ok {
+		return nil, fmt.Errorf(`missing %q`, member)
+	}
+	pointer, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf(`%q is not a string`, member)
+	}
+	if pointer == `` {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, `/`) {
+		return nil, fmt.Errorf(`%q is not a JSON pointer: %q does not start with "/"`, member, pointer)
+	}
+	tokens := strings.Split(pointer[1:], `/`)
+	for idx, token := range tokens {
+		token = strings.ReplaceAll(token, `~1`, `/`)
+		tokens[idx] = strings.ReplaceAll(token, `~0`, `~`)
+	}
+	return tokens, nil
+}
+
+func joinPointer(tokens []string) string {
+	loc := make(Location, len(tokens))
+	for idx, token := range tokens {
+		loc[idx] = token
+	}
+	return loc.Pointer()
+}
+
+// patchIndex interprets a reference token as an index into an array of the
+// given length. Only add may use "-" or an index one past the end.
+func patchIndex(token string, length int, adding bool) (int, error) {
+	if adding && token == `-` {
+		return length, nil
+	}
+	if !isAllDigits([]rune(token)) || token == `` || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf(`%q is not an array index`, token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf(`%q is not an array index: %w`, token, err)
+	}
+	if idx > length || (!adding && idx == length) {
+		return 0, fmt.Errorf(`index %d is out of range for an array of length %d`, idx, length)
+	}
+	return idx, nil
+}
+
+// patchChild looks up a single reference token in a container.
+func patchChild(container any, token string) (any, error) {
+	switch c := container.(type) {
+	case *Map:
+		value, ok := c.Get(token)
+		if !ok {
+			return nil, fmt.Errorf(`member %q not found`, token)
+		}
+		return value, nil
+	case []any:
+		idx, err := patchIndex(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		return c[idx], nil
+	default:
+		return nil, fmt.Errorf(`cannot look up %q in a %T`, token, container)
+	}
+}
+
+func patchGet(data any, path []string) (any, error) {
+	for depth, token := range path {
+		var err error
+		data, err = patchChild(data, token)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, joinPointer(path[:depth+1]), err)
+		}
+	}
+	return data, nil
+}
+
+// patchUpdate finds the container that holds the last token of path, calls
+// update on it, and stores the container it returns back in its parent.
+func patchUpdate(data any, path []string, depth int, update func(container any, token string) (any, error)) (any, error) {
+	if depth == len(path)-1 {
+		return update(data, path[depth])
+	}
+	child, err := patchChild(data, path[depth])
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, joinPointer(path[:depth+1]), err)
+	}
+	child, err = patchUpdate(child, path, depth+1, update)
+	if err != nil {
+		return nil, err
+	}
+	switch c := data.(type) {
+	case *Map:
+		c.Set(path[depth], child)
+	case []any:
+		idx, _ := patchIndex(path[depth], len(c), false)
+		c[idx] = child
+	}
+	return data, nil
+}
+
+func patchAdd(data any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchUpdate(data, path, 0, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case *Map:
+			c.Set(token, value)
+			return c, nil
+		case []any:
+			idx, err := patchIndex(token, len(c), true)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: %w`, joinPointer(path), err)
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf(`%s: cannot add to a %T`, joinPointer(path), container)
+		}
+	})
+}
+
+// patchReplace changes a value where it is, so a member of a map keeps
+// its place.
+func patchReplace(data any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return patchUpdate(data, path, 0, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case *Map:
+			if _, ok := c.Get(token); !ok {
+				return nil, fmt.Errorf(`%s: member %q not found`, joinPointer(path), token)
+			}
+			c.Set(token, value)
+			return c, nil
+		case []any:
+			idx, err := patchIndex(token, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: %w`, joinPointer(path), err)
+			}
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf(`%s: cannot replace in a %T`, joinPointer(path), container)
+		}
+	})
+}
+
+func patchRemove(data any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf(`cannot remove the whole document`)
+	}
+	return patchUpdate(data, path, 0, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case *Map:
+			if _, ok := c.Get(token); !ok {
+				return nil, fmt.Errorf(`%s: member %q not found`, joinPointer(path), token)
+			}
+			c.Delete(token)
+			return c, nil
+		case []any:
+			idx, err := patchIndex(token, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: %w`, joinPointer(path), err)
+			}
+			return append(c[:idx:idx], c[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf(`%s: cannot remove from a %T`, joinPointer(path), container)
+		}
+	})
+}
+
+// copyValue makes a deep copy of maps and arrays so they can be modified
+// safely.
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case *Map:
+		out := NewMap()
+		for _, key := range v.keys {
+			out.Set(key, copyValue(v.values[key]))
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = copyValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// ApplyPatchFile reads a patch file and applies it to a document.
+func ApplyPatchFile(data any, filename string) (any, error) {
+	patch, err := ParseDocument(filename, FormatUnknown)
+	if err != nil {
+		return nil, fmt.Errorf(`could not read patch: %w`, err)
+	}
+	patched, err := ApplyPatch(data, patch.Data)
+	if err != nil {
+		return nil, fmt.Errorf(`could not apply patch %q: %w`, filename, err)
+	}
+	return patched, nil
+}
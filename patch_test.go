@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PatchTestCase struct {
+	PatchFile       string
+	Patch           any
+	Path            string
+	ExpectedError   string
+	ExpectedResults []any
+}
+
+func (ptc PatchTestCase) Test(t *testing.T) {
+	t.Helper()
+	data, err := Parse(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+
+	var patched any
+	if ptc.PatchFile != `` {
+		patched, err = ApplyPatchFile(data, ptc.PatchFile)
+	} else {
+		patched, err = ApplyPatch(data, Normalize(ptc.Patch))
+	}
+	if ptc.ExpectedError != `` {
+		assert.ErrorContains(t, err, ptc.ExpectedError)
+		return
+	}
+	assert.NoError(t, err)
+
+	results, err := Evaluate(patched, ptc.Path)
+	assert.NoError(t, err, ptc.Path)
+	assert.Equal(t, ptc.ExpectedResults, Plain(results), ptc.Path)
+
+	unpatched, err := Evaluate(data, `animals.vertebrates.mammals[0]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{`horse`}, unpatched, `the original document should not be modified`)
+}
+
+var PatchTestCases = []PatchTestCase{
+	{
+		PatchFile: `test_data/patch.json`,
+		Path:      `animals.vertebrates`,
+		ExpectedResults: []any{
+			map[string]any{
+				`mammals`:  []any{`whale`, `shrew`, `cat`},
+				`reptiles`: []any{`lizard`, `snake`, `newt`},
+				`birds`:    []any{`robin`, `crow`},
+			},
+		},
+	},
+	{
+		PatchFile:       `test_data/patch.json`,
+		Path:            `animals.keys()`,
+		ExpectedResults: []any{[]any{`vertebrates`}},
+	},
+	{
+		PatchFile:       `test_data/patch.json`,
+		Path:            `minerals.changed`,
+		ExpectedResults: []any{[]any{`slate`, `schist`, `marble`}},
+	},
+	{
+		PatchFile:       `test_data/patch.json`,
+		Path:            `vegetables.flowers`,
+		ExpectedResults: []any{[]any{`rose`, `rose`, `magnolia`, `tulip`, `narcissus`}},
+	},
+	{
+		PatchFile: `test_data/merge_patch.json`,
+		Path:      `animals`,
+		ExpectedResults: []any{
+			map[string]any{
+				`vertebrates`: map[string]any{
+					`mammals`:  []any{`whale`},
+					`reptiles`: []any{`lizard`, `snake`, `newt`},
+					`birds`:    []any{`robin`, `crow`},
+				},
+			},
+		},
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `remove`, `path`: `/vegetables/flowers/1`},
+			map[string]any{`op`: `add`, `path`: `/vegetables/flowers/3`, `value`: nil},
+		},
+		Path:            `vegetables.flowers`,
+		ExpectedResults: []any{[]any{`rose`, `tulip`, `narcissus`, nil}},
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `replace`, `path`: ``, `value`: map[string]any{`a~b`: 1}},
+			map[string]any{`op`: `test`, `path`: `/a~0b`, `value`: 1.0},
+		},
+		Path:            `["a~b"]`,
+		ExpectedResults: []any{1},
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `replace`, `path`: `/animals/vertebrates`, `value`: 9},
+		},
+		Path:            `animals.keys()`,
+		ExpectedResults: []any{[]any{`vertebrates`, `invertebrates`}},
+	},
+	{
+		Patch:           map[string]any{`minerals`: map[string]any{`found`: []any{map[string]any{`k`: nil}}, `igneous`: nil}},
+		Path:            `minerals.found`,
+		ExpectedResults: []any{[]any{map[string]any{`k`: nil}}},
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `test`, `path`: `/animals/vertebrates/mammals/0`, `value`: `horse`},
+			map[string]any{`op`: `test`, `path`: `/animals/vertebrates/mammals/1`, `value`: `horse`},
+		},
+		ExpectedError: `patch operation 1 (test "/animals/vertebrates/mammals/1"): test failed: value is "shrew", not "horse"`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `remove`, `path`: `/animals/vertibrates/mammals`},
+		},
+		ExpectedError: `patch operation 0 (remove "/animals/vertibrates/mammals"): /animals/vertibrates: member "vertibrates" not found`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `add`, `path`: `/minerals/igneous/7`, `value`: `pumice`},
+		},
+		ExpectedError: `index 7 is out of range for an array of length 3`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `replace`, `path`: `/minerals/igneous/01`, `value`: `pumice`},
+		},
+		ExpectedError: `"01" is not an array index`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `move`, `from`: `/minerals`, `path`: `/minerals/igneous`},
+		},
+		ExpectedError: `cannot move "/minerals" into one of its own children`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `add`, `path`: `/minerals/precious`},
+		},
+		ExpectedError: `patch operation 0 (add "/minerals/precious"): missing "value"`,
+	},
+	{
+		Patch: []any{
+			map[string]any{`op`: `frobnicate`, `path`: `/minerals`},
+		},
+		ExpectedError: `unknown operation "frobnicate"`,
+	},
+}
+
+func TestPatch(t *testing.T) {
+	for _, tc := range PatchTestCases {
+		tc.Test(t)
+	}
+}
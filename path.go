@@ -10,8 +10,6 @@ import (
 	"unicode"
 
 	"golang.org/x/exp/constraints"
-
-	yaml "gopkg.in/yaml.v3"
 )
 
 type PathChunkType int
@@ -24,10 +22,57 @@ const (
 	PCTMember
 	PCTFunction
 	PCTStar
+	PCTParent
+	PCTRoot
 )
 
 type Path []rune
 
+// Result is a value found by a search, along with where it was found and
+// the result it was found in.
+type Result struct {
+	Value    any
+	Location Location
+	Parent   *Result
+}
+
+// child makes a result for a value found inside this one.
+func (r *Result) child(value any, key any) Result {
+	return Result{Value: value, Location: r.Location.Child(key), Parent: r}
+}
+
+// derived makes a result for a value that a function made from this one.
+func (r Result) derived(value any, function string) Result {
+	return Result{Value: value, Location: r.Location.Child(FunctionKey(function)), Parent: &r}
+}
+
+// root follows a result's ancestry back to where the search started.
+func (r *Result) root() *Result {
+	for r.Parent != nil {
+		r = r.Parent
+	}
+	return r
+}
+
+// mapChildren makes a result for each value in a map, in order.
+func mapChildren(r Result, m *Map) []Result {
+	children := make([]Result, 0, m.Len())
+	for _, key := range m.Keys() {
+		value, _ := m.Get(key)
+		children = append(children, r.child(value, key))
+	}
+	return children
+}
+
+// Values extracts just the values from a list of results.
+func Values(results []Result) []any {
+	values := make([]any, len(results))
+	for idx, result := range results {
+		values[idx] = result.Value
+	}
+	return values
+}
+
 func NewPath(s string) *Path {
 	r := []rune(s)
 	return (*Path)(&r)
@@ -41,34 +86,39 @@ func (p *Path) RuneArray() []rune {
 	return []rune(*p)
 }
 
-func scanForBrace(s []rune) int {
+// scanForBrace finds the "]" that closes a brace, skipping over nested
+// braces, parentheses and quoted strings. It reports false if there isn't
+// one.
+func scanForBrace(s []rune) (int, bool) {
 	state := make([]rune, 0)
-	var pos int
-	for {
-		if len(state) == 0 {
-			switch s[0] {
-			case ']':
-				return pos
-			case '\'':
-				state = append([]rune{'\''}, state...)
-			case '"':
-				state = append([]rune{'"'}, state...)
-			case '(':
-				state = append([]rune{')'}, state...)
-			case '[':
-				state = append([]rune{']'}, state...)
-			}
-		} else {
-			if s[0] == state[0] {
+	for pos := 0; pos < len(s); pos++ {
+		r := s[pos]
+		if len(state) != 0 && (state[0] == '\'' || state[0] == '"') {
+			switch r {
+			case '\\':
+				pos++
+			case state[0]:
 				state = state[1:]
 			}
+			continue
 		}
-		pos++
-		s = s[1:]
-		if len(s) == 0 {
-			return pos
+		switch r {
+		case ']', ')':
+			if len(state) == 0 && r == ']' {
+				return pos, true
+			}
+			if len(state) != 0 && r == state[0] {
+				state = state[1:]
+			}
+		case '\'', '"':
+			state = append([]rune{r}, state...)
+		case '(':
+			state = append([]rune{')'}, state...)
+		case '[':
+			state = append([]rune{']'}, state...)
 		}
 	}
+	return len(s), false
 }
 
 func scanMember(s []rune) (int, bool) {
@@ -100,116 +150,122 @@ func isAllDigits(s []rune) bool {
 	return true
 }
 
-func (p *Path) chunk() (string, PathChunkType) {
+// chunk takes the next part of the path off the front. Errors are
+// *QuerySyntaxError with an offset from where the chunk started.
+func (p *Path) chunk() (string, PathChunkType, error) {
 	s := p.RuneArray()
 	if len(s) == 0 {
-		return ``, PCTEmpty
+		return ``, PCTEmpty, nil
 	}
 	switch s[0] {
 	case '.':
 		*p = Path(s[1:])
-		return `.`, PCTDot
+		return `.`, PCTDot, nil
+	case '^':
+		*p = Path(s[1:])
+		return `^`, PCTParent, nil
+	case '$':
+		*p = Path(s[1:])
+		return `$`, PCTRoot, nil
 	case '[':
 		s = s[1:]
-		clen := scanForBrace(s)
+		clen, ok := scanForBrace(s)
+		if !ok {
+			return ``, PCTEmpty, &QuerySyntaxError{Message: `"[" is never closed with "]"`}
+		}
 		chunk := string(s[:clen])
 		chunk = strings.TrimSpace(chunk)
 		*p = Path(s[clen+1:])
+		if chunk == `` {
+			return ``, PCTEmpty, &QuerySyntaxError{Message: `empty brackets; expected an index, a key, "*" or a test`}
+		}
 		if chunk == `*` {
-			return chunk, PCTStar
+			return chunk, PCTStar, nil
 		}
-		if strings.HasPrefix(chunk, `'`) && strings.HasSuffix(chunk, `'`) {
-			return strings.Trim(chunk, `'`), PCTMember
+		if len(chunk) > 1 && strings.HasPrefix(chunk, `'`) && strings.HasSuffix(chunk, `'`) {
+			return strings.Trim(chunk, `'`), PCTMember, nil
 		}
-		if strings.HasPrefix(chunk, `"`) && strings.HasSuffix(chunk, `"`) {
-			return strings.Trim(chunk, `"`), PCTMember
+		if len(chunk) > 1 && strings.HasPrefix(chunk, `"`) && strings.HasSuffix(chunk, `"`) {
+			return strings.Trim(chunk, `"`), PCTMember, nil
 		}
 		if isAllDigits([]rune(chunk)) {
-			return chunk, PCTIndex
+			return chunk, PCTIndex, nil
 		}
-		return chunk, PCTBrace
+		return chunk, PCTBrace, nil
 	default:
 		mlen, allDigits := scanMember(s)
+		if mlen == 0 {
+			return ``, PCTEmpty, &QuerySyntaxError{Message: fmt.Sprintf(`unexpected %q`, s[0])}
+		}
 		chunk := string(s[:mlen])
 		*p = Path(s[mlen:])
 		s = p.RuneArray()
 		if len(s) != 0 && s[0] == '(' {
-			*p = Path([]rune(strings.TrimLeft(string(s), `()`)))
-			return chunk, PCTFunction
+			if len(s) == 1 || s[1] != ')' {
+				return ``, PCTEmpty, &QuerySyntaxError{Offset: mlen + 1, Message: `expected ")"; functions don't take arguments`}
+			}
+			*p = Path(s[2:])
+			return chunk, PCTFunction, nil
 		}
 		if allDigits {
-			return chunk, PCTIndex
+			return chunk, PCTIndex, nil
 		}
-		return chunk, PCTMember
+		return chunk, PCTMember, nil
 	}
-
 }
 
-func evalIndex(data []any, index int) []any {
+func evalIndex(data []Result, index int) []Result {
 	var part int
-	for _, value := range data {
-		switch array := value.(type) {
+	for _, item := range data {
+		result := item
+		switch array := result.Value.(type) {
 		case []any:
 			if len(array) <= index {
 				continue
 			}
-			data[part] = array[index]
+			data[part] = result.child(array[index], index)
 			part++
-		case map[string]any:
-			if len(array) <= index {
-				continue
-			}
-			data[part] = array[fmt.Sprint(index)]
-			part++
-		case map[any]any:
-			if len(array) <= index {
+		case *Map:
+			value, ok := array.Get(strconv.Itoa(index))
+			if !ok {
 				continue
 			}
-			data[part] = array[index]
+			data[part] = result.child(value, strconv.Itoa(index))
 			part++
 		}
 	}
 	return data[:part]
 }
 
-func evalMember(data []any, member string) []any {
-	var (
-		part  int
-		value any
-		ok    bool
-	)
-	for _, item := range data {
-		var dict map[string]any
-		dict, ok = item.(map[string]any)
-		if ok {
-			value, ok = dict[member]
-			if !ok {
-				continue
-			}
-		} else {
-			var dict map[any]any
-			dict, ok = item.(map[any]any)
-			value, ok = dict[member]
-			if !ok {
-				continue
-			}
+func evalMember(data []Result, member string) []Result {
+	var part int
+	for _, result := range data {
+		item := result
+		dict, ok := item.Value.(*Map)
+		if !ok {
+			continue
+		}
+		value, ok := dict.Get(member)
+		if !ok {
+			continue
 		}
-		data[part] = value
+		data[part] = item.child(value, member)
 		part++
 	}
 	return data[:part]
 }
 
-func evalStar(data []any) []any {
-	out := make([]any, 0)
-	for _, item := range data {
-		switch v := item.(type) {
+func evalStar(data []Result) []Result {
+	out := make([]Result, 0)
+	for _, result := range data {
+		item := result
+		switch v := item.Value.(type) {
 		case []any:
-			out = append(out, v...)
-		case map[string]any:
-			for _, vi := range v {
-				out = append(out, vi)
+			for idx, vi := range v {
+				out = append(out, item.child(vi, idx))
 			}
+		case *Map:
+			out = append(out, mapChildren(item, v)...)
 		default:
 			continue
 		}
@@ -217,139 +273,143 @@ func evalStar(data []any) []any {
 	return out
 }
 
-func evalFuncLen(data []any) []any {
+func evalFuncLen(data []Result) []Result {
 	for idx, item := range data {
-		switch v := item.(type) {
+		length := 1
+		switch v := item.Value.(type) {
 		case []any:
-			data[idx] = len(v)
-		case map[string]any:
-			data[idx] = len(v)
+			length = len(v)
+		case *Map:
+			length = v.Len()
 		case string:
-			data[idx] = len(v)
-		default:
-			data[idx] = 1
+			length = len(v)
 		}
+		data[idx] = item.derived(length, `length`)
 	}
 	return data
 }
 
-func evalFuncJSON(data []any) ([]any, error) {
+func evalFuncJSON(data []Result) ([]Result, error) {
 	for idx, item := range data {
-		bytes, err := json.Marshal(item)
+		bytes, err := OutputStyle.JSON(item.Value, false)
 		if err != nil {
 			return nil, fmt.Errorf(`could not marshal item %d as JSON: %w`, idx, err)
 		}
-		data[idx] = string(bytes)
+		data[idx] = item.derived(string(bytes), `json`)
 	}
 	return data, nil
 }
 
-func evalFuncJSONPretty(data []any) ([]any, error) {
+func evalFuncJSONPretty(data []Result) ([]Result, error) {
 	for idx, item := range data {
-		bytes, err := json.MarshalIndent(item, ``, `    `)
+		bytes, err := OutputStyle.JSON(item.Value, true)
 		if err != nil {
 			return nil, fmt.Errorf(`could not marshal item %d as JSON-Pretty: %w`, idx, err)
 		}
-		data[idx] = string(bytes)
+		data[idx] = item.derived(string(bytes), `jsonpretty`)
 	}
 	return data, nil
 }
 
-func evalFuncYAML(data []any) ([]any, error) {
+func evalFuncYAML(data []Result) ([]Result, error) {
 	for idx, item := range data {
-		bytes, err := yaml.Marshal(item)
+		bytes, err := OutputStyle.YAML(item.Value)
 		if err != nil {
 			return nil, fmt.Errorf(`could not marshal item %d as YAML: %w`, idx, err)
 		}
-		data[idx] = string(bytes)
+		data[idx] = item.derived(string(bytes), `yaml`)
 	}
 	return data, nil
 }
 
-func evalFuncKeys(data []any) []any {
+func evalFuncKeys(data []Result) []Result {
 	var part int
 	for _, item := range data {
-		switch dict := item.(type) {
-		case map[string]any:
-			keys := make([]any, 0, len(dict))
-			for key := range dict {
-				keys = append(keys, key)
-			}
-			data[part] = keys
-			part++
-		case map[any]any:
-			keys := make([]any, 0, len(dict))
-			for key := range dict {
-				key = append(keys, fmt.Sprint(key))
-			}
-			data[part] = keys
-			part++
+		dict, ok := item.Value.(*Map)
+		if !ok {
+			continue
+		}
+		keys := make([]any, 0, dict.Len())
+		for _, key := range dict.Keys() {
+			keys = append(keys, key)
 		}
+		data[part] = item.derived(keys, `keys`)
+		part++
 	}
 	return data[:part]
 }
 
-func evalFuncFlatten(data []any) []any {
-	var out = make([]any, 0)
-	for _, item := range data {
-		switch value := item.(type) {
+func evalFuncFlatten(data []Result) []Result {
+	var out = make([]Result, 0)
+	for _, result := range data {
+		item := result
+		switch value := item.Value.(type) {
 		case []any:
-			out = append(out, value...)
-		case map[string]any:
-			for _, v := range value {
-				out = append(out, v)
-			}
-		case map[any]any:
-			for _, v := range value {
-				out = append(out, v)
+			for idx, v := range value {
+				out = append(out, item.child(v, idx))
 			}
+		case *Map:
+			out = append(out, mapChildren(item, value)...)
 		default:
-			out = append(out, value)
+			out = append(out, item)
 		}
 	}
 	return out
 }
 
-func evalFuncResults(data []any) []any {
-	return []any{data}
+func evalFuncResults(data []Result) []Result {
+	return []Result{{Value: Values(data), Location: Location{FunctionKey(`results`)}}}
+}
+
+func evalFuncPath(data []Result) []Result {
+	for idx, item := range data {
+		data[idx] = item.derived(item.Location.String(), `path`)
+	}
+	return data
+}
+
+func evalFuncPaths(data []Result) []Result {
+	paths := make([]any, len(data))
+	for idx, item := range data {
+		paths[idx] = item.Location.String()
+	}
+	return []Result{{Value: paths, Location: Location{FunctionKey(`paths`)}}}
 }
 
-func evalFuncJSONEval(data []any) ([]any, error) {
+func evalFuncJSONEval(data []Result) ([]Result, error) {
 	var part int
 	for rnum, item := range data {
-		switch result := item.(type) {
+		switch result := item.Value.(type) {
 		case string:
-			var value any
-			err := json.Unmarshal([]byte(result), &value)
+			value, err := decodeJSON([]byte(result))
 			if err != nil {
 				log.Print(result)
 				return nil, fmt.Errorf(`could not unmarshal result %d as JSON: %w`, rnum, err)
 			}
-			data[part] = value
+			data[part] = item.derived(value, `jsoneval`)
 			part++
 		}
 	}
 	return data[:part], nil
 }
 
-func evalFuncYAMLEval(data []any) ([]any, error) {
+func evalFuncYAMLEval(data []Result) ([]Result, error) {
 	var part int
 	for rnum, item := range data {
-		switch result := item.(type) {
+		switch result := item.Value.(type) {
 		case string:
-			var value any
-			err := yaml.Unmarshal([]byte(result), &value)
+			value, err := decodeYAML([]byte(result))
 			if err != nil {
 				return nil, fmt.Errorf(`could not unmarshal result %d as YAML: %w`, rnum, err)
 			}
-			data[part] = value
+			data[part] = item.derived(value, `yamleval`)
 			part++
 		}
 	}
 	return data[:part], nil
 }
 
-func evalFunction(data []any, function string) ([]any, error) {
+func evalFunction(data []Result, function string) ([]Result, error) {
 	switch function {
 	case `len`, `length`:
 		return evalFuncLen(data), nil
@@ -369,11 +429,35 @@ func evalFunction(data []any, function string) ([]any, error) {
 		return evalFuncFlatten(data), nil
 	case `results`:
 		return evalFuncResults(data), nil
+	case `path`:
+		return evalFuncPath(data), nil
+	case `paths`:
+		return evalFuncPaths(data), nil
+	case `parent`:
+		return evalParent(data), nil
+	case `root`:
+		return evalRoot(data), nil
 	default:
-		return nil, fmt.Errorf(`unknown function`)
+		return nil, &UnknownFunctionError{Name: function}
 	}
 }
 
+// FunctionNames are the functions that can be used in a search path.
+var FunctionNames = []string{
+	`flat`, `flatten`, `jeval`, `jpretty`, `js`, `json`, `jsoneval`,
+	`jsonpretty`, `jspretty`, `keys`, `len`, `length`, `parent`, `path`,
+	`paths`, `results`, `root`, `yaml`, `yamleval`, `yeval`, `yml`,
+}
+
+func isFunctionName(name string) bool {
+	for _, known := range FunctionNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
 func typedCompare[T constraints.Ordered](l, r T, comparison string) bool {
 	switch strings.TrimSpace(comparison) {
 	case `<`:
@@ -395,89 +479,71 @@ func typedCompare[T constraints.Ordered](l, r T, comparison string) bool {
 
 func compare(lval any, rval string, comparison string) bool {
 	switch lv := lval.(type) {
-	case int:
-		rv, err := strconv.Atoi(rval)
-		if err != nil {
-			return false
-		}
-		return typedCompare(lv, rv, comparison)
+	case int, json.Number, float64:
+		return compareNumber(lv, rval, comparison)
 	case string:
 		rval, err := strconv.Unquote(rval)
 		if err != nil {
 			return false
 		}
 		return typedCompare(lv, rval, comparison)
-	case float64:
-		rv, err := strconv.ParseFloat(rval, 64)
+	case bool:
+		rv, err := strconv.ParseBool(rval)
 		if err != nil {
 			return false
 		}
-		return typedCompare(lv, rv, comparison)
+		switch strings.TrimSpace(comparison) {
+		case `==`:
+			return lv == rv
+		case `!=`:
+			return lv != rv
+		default:
+			return false
+		}
 	default:
 		return false
 	}
 }
 
-func evalBrace(data []any, expression string) ([]any, error) {
-	matches := regexp.MustCompile(`^(.*?)(<=?|>=?|[!=]=)(.*)$`).FindStringSubmatch(expression)
+var comparisonRegexp = regexp.MustCompile(`^(.*?)(<=?|>=?|[!=]=)(.*)$`)
+
+// splitComparison breaks the expression in a brace into the path to test,
+// the comparison and the value to compare with.
+func splitComparison(expression string) (lpath, comparison, rval string, err error) {
+	matches := comparisonRegexp.FindStringSubmatch(expression)
 	if matches == nil {
-		return nil, fmt.Errorf(`don't know how to interpret %q`, expression)
+		return ``, ``, ``, &QuerySyntaxError{Path: expression, Message: `expected a test like "path == value"`}
 	}
-	lpath := strings.TrimSpace(matches[1])
-	comparison := strings.TrimSpace(matches[2])
-	rval := strings.TrimSpace(matches[3])
-	out := make([]any, 0)
-	for idx, item := range data {
-		switch subitems := item.(type) {
+	return strings.TrimSpace(matches[1]), strings.TrimSpace(matches[2]), strings.TrimSpace(matches[3]), nil
+}
+
+func evalBrace(data []Result, expression string) ([]Result, error) {
+	lpath, comparison, rval, err := splitComparison(expression)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Result, 0)
+	for idx, result := range data {
+		item := result
+		var children []Result
+		switch subitems := item.Value.(type) {
 		case []any:
-		arrayloop:
-			for _, subitem := range subitems {
-				lmatches, err := Evaluate(subitem, lpath)
-				if err != nil {
-					return nil, fmt.Errorf(`could not evaluate lpath %q for array item %d: %w`, lpath, idx, err)
-				}
-				if len(lmatches) == 0 {
-					continue
-				}
-				for _, lval := range lmatches {
-					if compare(lval, rval, comparison) {
-						out = append(out, subitem)
-						continue arrayloop
-					}
-				}
+			for sidx, subitem := range subitems {
+				children = append(children, item.child(subitem, sidx))
 			}
-		case map[string]any:
-		stringloop:
-			for _, subitem := range subitems {
-				lmatches, err := Evaluate(subitem, lpath)
-				if err != nil {
-					return nil, fmt.Errorf(`could not evaluate lpath %q for dict item %d: %w`, lpath, idx, err)
-				}
-				if len(lmatches) == 0 {
-					continue
-				}
-				for _, lval := range lmatches {
-					if compare(lval, rval, comparison) {
-						out = append(out, subitem)
-						continue stringloop
-					}
-				}
+		case *Map:
+			children = mapChildren(item, subitems)
+		}
+	childloop:
+		for _, child := range children {
+			lmatches, err := evaluateFrom(child, lpath, false)
+			if err != nil {
+				return nil, fmt.Errorf(`could not evaluate lpath %q for item %d: %w`, lpath, idx, err)
 			}
-		case map[any]any:
-		anyloop:
-			for _, subitem := range subitems {
-				lmatches, err := Evaluate(subitem, lpath)
-				if err != nil {
-					return nil, fmt.Errorf(`could not evaluate lpath %q for map item %d: %w`, lpath, idx, err)
-				}
-				if len(lmatches) == 0 {
-					continue
-				}
-				for _, lval := range lmatches {
-					if compare(lval, rval, comparison) {
-						out = append(out, subitem)
-						continue anyloop
-					}
+			for _, lval := range lmatches {
+				if compare(lval.Value, rval, comparison) {
+					out = append(out, child)
+					continue childloop
 				}
 			}
 		}
@@ -485,14 +551,130 @@ func evalBrace(data []any, expression string) ([]any, error) {
 	return out, nil
 }
 
+// evalParent replaces each result with the result it was found in. Results
+// found in the same parent only produce it once.
+func evalParent(data []Result) []Result {
+	out := make([]Result, 0, len(data))
+	seen := make(map[*Result]bool)
+	for _, item := range data {
+		if item.Parent == nil || seen[item.Parent] {
+			continue
+		}
+		seen[item.Parent] = true
+		out = append(out, *item.Parent)
+	}
+	return out
+}
+
+// evalRoot replaces the results with the value the search started from.
+func evalRoot(data []Result) []Result {
+	out := make([]Result, 0, 1)
+	seen := make(map[*Result]bool)
+	for idx := range data {
+		root := data[idx].root()
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		out = append(out, *root)
+	}
+	return out
+}
+
+// Evaluate searches data with a path and returns the values it finds.
 func Evaluate(data any, path string) ([]any, error) {
+	results, err := EvaluateResults(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return Values(results), nil
+}
+
+// EvaluateResults searches data with a path and returns the values it finds
+// along with their locations in data.
+func EvaluateResults(data any, path string) ([]Result, error) {
+	if err := CheckPath(path); err != nil {
+		return nil, err
+	}
+	return evaluateFrom(Result{Value: data, Location: Location{}}, path, false)
+}
+
+// EvaluateStrict is like EvaluateResults, but returns a *NoResultsError
+// instead of an empty list if any part of the path finds nothing.
+func EvaluateStrict(data any, path string) ([]Result, error) {
+	if err := CheckPath(path); err != nil {
+		return nil, err
+	}
+	return evaluateFrom(Result{Value: data, Location: Location{}}, path, true)
+}
+
+// CheckPath reads a whole search path without searching anything, so that
+// mistakes are found even in parts of the path a search would never reach.
+// It returns a *QuerySyntaxError or *UnknownFunctionError.
+func CheckPath(path string) error {
+	p := NewPath(path)
+	runes := p.RuneArray()
+	for {
+		offset := len(runes) - len(*p)
+		chunk, chunkType, err := p.chunk()
+		if err != nil {
+			return locateQueryError(err, path, offset)
+		}
+		switch chunkType {
+		case PCTEmpty:
+			return nil
+		case PCTIndex:
+			if _, err := strconv.Atoi(chunk); err != nil {
+				return &QuerySyntaxError{Path: path, Offset: offset, Message: fmt.Sprintf(`%q is not a valid index`, chunk)}
+			}
+		case PCTFunction:
+			if !isFunctionName(chunk) {
+				return &UnknownFunctionError{Name: chunk, Path: path, Offset: offset}
+			}
+		case PCTBrace:
+			lpath, _, _, err := splitComparison(chunk)
+			if err == nil {
+				err = CheckPath(lpath)
+			}
+			if err != nil {
+				return locateQueryError(err, path, braceOffset(runes, offset))
+			}
+		}
+	}
+}
+
+// braceOffset finds where the expression inside the brace that starts at
+// offset begins.
+func braceOffset(path []rune, offset int) int {
+	inner := path[offset+1:]
+	return offset + 1 + len(inner) - len([]rune(strings.TrimLeftFunc(string(inner), unicode.IsSpace)))
+}
+
+// evaluateFrom searches from a result that may itself have been found by
+// another search, so that the path can refer to its ancestors.
+func evaluateFrom(start Result, path string, strict bool) ([]Result, error) {
 	p := NewPath(path)
-	results := []any{data}
+	runes := p.RuneArray()
+	results := []Result{start}
+	var (
+		before []Result
+		offset int
+	)
 	for {
 		if len(results) == 0 {
+			if strict && len(before) != 0 {
+				return nil, newNoResultsError(runes, offset, len(runes)-len(*p), before)
+			}
 			return results, nil
 		}
-		chunk, chunkType := p.chunk()
+		if strict {
+			before = append(before[:0], results...)
+		}
+		offset = len(runes) - len(*p)
+		chunk, chunkType, err := p.chunk()
+		if err != nil {
+			return nil, locateQueryError(err, path, offset)
+		}
 		switch chunkType {
 		case PCTEmpty:
 			return results, nil
@@ -501,27 +683,35 @@ func Evaluate(data any, path string) ([]any, error) {
 		case PCTIndex:
 			idx, err := strconv.Atoi(chunk)
 			if err != nil {
-				return nil, fmt.Errorf(`incorrectly interpreted %q as an index: %w`, chunk, err)
+				return nil, &QuerySyntaxError{Path: path, Offset: offset, Message: fmt.Sprintf(`%q is not a valid index`, chunk)}
 			}
 			results = evalIndex(results, idx)
 		case PCTMember:
 			results = evalMember(results, chunk)
 		case PCTStar:
 			results = evalStar(results)
+		case PCTParent:
+			results = evalParent(results)
+		case PCTRoot:
+			results = evalRoot(results)
 		case PCTBrace:
-			var err error
 			results, err = evalBrace(results, chunk)
 			if err != nil {
+				if located := locateQueryError(err, path, braceOffset(runes, offset)); located != err {
+					return nil, located
+				}
 				return nil, fmt.Errorf(`unable to evaluate expression in brace %q: %w`, chunk, err)
 			}
 		case PCTFunction:
-			var err error
 			results, err = evalFunction(results, chunk)
 			if err != nil {
+				if located := locateQueryError(err, path, offset); located != err {
+					return nil, located
+				}
 				return nil, fmt.Errorf(`unable to evaluate function %q: %w`, chunk, err)
 			}
 		default:
-			return nil, fmt.Errorf(`unknown chunk type %q pulled from path %q`, chunk, p.String())
+			return nil, &QuerySyntaxError{Path: path, Offset: offset, Message: fmt.Sprintf(`could not understand %q`, chunk)}
 		}
 	}
 }
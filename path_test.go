@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -101,7 +102,7 @@ var PathTestCases = []PathTestCase{
 	{
 		Path: `animals.invertebrates.yaml()`,
 		ExpectedResults: []any{
-			"insects:\n    - fly\n    - ant\nmollusks:\n    - clam\n",
+			"mollusks:\n    - clam\ninsects:\n    - fly\n    - ant\n",
 		},
 	},
 	{
@@ -113,19 +114,19 @@ var PathTestCases = []PathTestCase{
 	{
 		Path: `animals.invertebrates.json()`,
 		ExpectedResults: []any{
-			`{"insects":["fly","ant"],"mollusks":["clam"]}`,
+			`{"mollusks":["clam"],"insects":["fly","ant"]}`,
 		},
 	},
 	{
 		Path: `animals.invertebrates.jsonpretty()`,
 		ExpectedResults: []any{
 			`{
+    "mollusks": [
+        "clam"
+    ],
     "insects": [
         "fly",
         "ant"
-    ],
-    "mollusks": [
-        "clam"
     ]
 }`,
 		},
@@ -162,6 +163,125 @@ var PathTestCases = []PathTestCase{
 			},
 		},
 	},
+	{
+		Path: `minerals.igneous[1].path()`,
+		ExpectedResults: []any{
+			`minerals.igneous[1]`,
+		},
+	},
+	{
+		Path: `animals.vertebrates[*][. == "cat"].path()`,
+		ExpectedResults: []any{
+			`animals.vertebrates.mammals[2]`,
+		},
+	},
+	{
+		Path: `vegetables.trees.flatten()[. == "oak"].path()`,
+		ExpectedResults: []any{
+			`vegetables.trees.deciduous[0]`,
+		},
+	},
+	{
+		Path: `animals.keys().path()`,
+		ExpectedResults: []any{
+			`animals.keys()`,
+		},
+	},
+	{
+		Path: `animals.keys()[. == "invertebrates"].path()`,
+		ExpectedResults: []any{
+			`animals.keys()[1]`,
+		},
+	},
+	{
+		Path: `minerals.igneous.length().path()`,
+		ExpectedResults: []any{
+			`minerals.igneous.length()`,
+		},
+	},
+	{
+		Path: `minerals.igneous[*].results().path()`,
+		ExpectedResults: []any{
+			`results()`,
+		},
+	},
+	{
+		Path: `animals.vertebrates.keys()^.path()`,
+		ExpectedResults: []any{
+			`animals.vertebrates`,
+		},
+	},
+	{
+		Path: `minerals.igneous[*].paths()`,
+		ExpectedResults: []any{
+			[]any{`minerals.igneous[0]`, `minerals.igneous[1]`, `minerals.igneous[2]`},
+		},
+	},
+	{
+		Path: `[*][length() == 3].path()`,
+		ExpectedResults: []any{
+			`minerals.igneous`, `minerals.metamorphic`, `minerals.sedimentary`,
+		},
+	},
+	{
+		Path: `path()`,
+		ExpectedResults: []any{
+			`.`,
+		},
+	},
+	{
+		Path: `animals.vertebrates.mammals^.reptiles`,
+		ExpectedResults: []any{
+			[]any{`lizard`, `snake`, `newt`},
+		},
+	},
+	{
+		Path: `animals.vertebrates.mammals.parent().parent().keys().length()`,
+		ExpectedResults: []any{
+			2,
+		},
+	},
+	{
+		Path: `minerals[*][. == "shale"]^.path()`,
+		ExpectedResults: []any{
+			`minerals.sedimentary`,
+		},
+	},
+	{
+		Path: `vegetables.trees[*][*]^^.path()`,
+		ExpectedResults: []any{
+			`vegetables.trees`,
+		},
+	},
+	{
+		Path: `minerals.igneous[0]$.meta.description.jeval().type`,
+		ExpectedResults: []any{
+			`yaml document`,
+		},
+	},
+	{
+		Path: `minerals[*].root().paths()`,
+		ExpectedResults: []any{
+			[]any{`.`},
+		},
+	},
+	{
+		Path: `animals[mammals^^.invertebrates.mollusks[0] == "clam"].path()`,
+		ExpectedResults: []any{
+			`animals.vertebrates`,
+		},
+	},
+	{
+		Path: `animals[$.minerals.igneous.length() == 3].path()`,
+		ExpectedResults: []any{
+			`animals.vertebrates`,
+			`animals.invertebrates`,
+		},
+	},
+	{
+		Path:            `^`,
+		ExpectedResults: []any{},
+	},
 }
 
 func TestPath(t *testing.T) {
@@ -169,3 +289,121 @@ func TestPath(t *testing.T) {
 		tc.Test(t)
 	}
 }
+
+type PathSyntaxTestCase struct {
+	Path           string
+	ExpectedOffset int
+	ExpectedError  string
+}
+
+func (pstc PathSyntaxTestCase) Test(t *testing.T) {
+	t.Helper()
+	err := CheckPath(pstc.Path)
+	if pstc.ExpectedError == `` {
+		assert.NoError(t, err, pstc.Path)
+		return
+	}
+	assert.ErrorContains(t, err, pstc.ExpectedError, pstc.Path)
+	report := NewErrorReport(err, ExitQuery)
+	if assert.NotNil(t, report.Offset, pstc.Path) {
+		assert.Equal(t, pstc.ExpectedOffset, *report.Offset, pstc.Path)
+	}
+}
+
+var PathSyntaxTestCases = []PathSyntaxTestCase{
+	{
+		Path: `animals[vertebrates.mammals[0] == "horse"].path()`,
+	},
+	{
+		Path: `contacts[name == "a]b"]`,
+	},
+	{
+		Path:           `animals[x == 3`,
+		ExpectedOffset: 7,
+		ExpectedError:  `"[" is never closed`,
+	},
+	{
+		Path:           `[`,
+		ExpectedOffset: 0,
+		ExpectedError:  `"[" is never closed`,
+	},
+	{
+		Path:           `animals["vertebrates]`,
+		ExpectedOffset: 7,
+		ExpectedError:  `"[" is never closed`,
+	},
+	{
+		Path:           `animals.lenght()`,
+		ExpectedOffset: 8,
+		ExpectedError:  `did you mean "length()"?`,
+	},
+	{
+		Path:           `animals[ keys() == 1 ].nope()`,
+		ExpectedOffset: 23,
+		ExpectedError:  "    animals[ keys() == 1 ].nope()\n                           ^",
+	},
+	{
+		Path:           `minerals[*][ .nope() == 1 ]`,
+		ExpectedOffset: 14,
+		ExpectedError:  `unknown function "nope()"`,
+	},
+	{
+		Path:           `animals.keys(1)`,
+		ExpectedOffset: 13,
+		ExpectedError:  `functions don't take arguments`,
+	},
+	{
+		Path:           `animals[]`,
+		ExpectedOffset: 7,
+		ExpectedError:  `empty brackets`,
+	},
+	{
+		Path:           `animals[ x ~ 3 ]`,
+		ExpectedOffset: 9,
+		ExpectedError:  `expected a test`,
+	},
+	{
+		Path:           `animals vertebrates`,
+		ExpectedOffset: 7,
+		ExpectedError:  `unexpected ' '`,
+	},
+	{
+		Path:           `[99999999999999999999999]`,
+		ExpectedOffset: 0,
+		ExpectedError:  `is not a valid index`,
+	},
+}
+
+func TestPathSyntax(t *testing.T) {
+	for _, tc := range PathSyntaxTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	for _, name := range FunctionNames {
+		_, err := evalFunction([]Result{{Value: NewMap()}}, name)
+		var ufe *UnknownFunctionError
+		assert.False(t, errors.As(err, &ufe), name)
+	}
+}
+
+func FuzzPath(f *testing.F) {
+	for _, tc := range PathTestCases {
+		f.Add(tc.Path)
+	}
+	for _, tc := range PathSyntaxTestCases {
+		f.Add(tc.Path)
+	}
+	data, err := Parse(`test_data/test.yaml`, FormatYAML)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, path string) {
+		if err := CheckPath(path); err != nil {
+			return
+		}
+		EvaluateStrict(data, path)
+		Evaluate(data, path)
+	})
+}
@@ -15,11 +15,13 @@ func _() {
 	_ = x[PCTMember-4]
 	_ = x[PCTFunction-5]
 	_ = x[PCTStar-6]
+	_ = x[PCTParent-7]
+	_ = x[PCTRoot-8]
 }
 
-const _PathChunkType_name = "PCTEmptyPCTDotPCTBracePCTIndexPCTMemberPCTFunctionPCTStar"
+const _PathChunkType_name = "PCTEmptyPCTDotPCTBracePCTIndexPCTMemberPCTFunctionPCTStarPCTParentPCTRoot"
 
-var _PathChunkType_index = [...]uint8{0, 8, 14, 22, 30, 39, 50, 57}
+var _PathChunkType_index = [...]uint8{0, 8, 14, 22, 30, 39, 50, 57, 66, 73}
 
 func (i PathChunkType) String() string {
 	if i < 0 || i >= PathChunkType(len(_PathChunkType_index)-1) {
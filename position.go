@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Position is a line and column in a source file, both counting from 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf(`%d:%d`, p.Line, p.Column)
+}
+
+// IsValid reports whether the position was actually found.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// SourcePosition is a Position along with the file it is in.
+type SourcePosition struct {
+	Filename string
+	Position
+}
+
+// String renders the position as file:line:col, which most editors
+// understand.
+func (sp SourcePosition) String() string {
+	if !sp.IsValid() {
+		return sp.Filename
+	}
+	return fmt.Sprintf(`%s:%s`, sp.Filename, sp.Position)
+}
+
+// Positions maps the JSON Pointer of each value in a document to where the
+// value starts in the source file.
+type Positions map[string]Position
+
+// yamlPositions records where every value in a YAML document starts.
+func yamlPositions(source []byte) (Positions, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(source, &root); err != nil {
+		return nil, err
+	}
+	positions := make(Positions)
+	if root.Kind == yaml.DocumentNode && len(root.Content) != 0 {
+		walkYAMLPositions(root.Content[0], Location{}, positions)
+	}
+	return positions, nil
+}
+
+func walkYAMLPositions(node *yaml.Node, loc Location, positions Positions) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	positions[loc.Pointer()] = Position{Line: node.Line, Column: node.Column}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			walkYAMLPositions(node.Content[idx+1], loc.Child(node.Content[idx].Value), positions)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range node.Content {
+			walkYAMLPositions(item, loc.Child(idx), positions)
+		}
+	}
+}
+
+// jsonPositions records where every value in a JSON document starts. The
+// standard decoder only reports byte offsets, so they are converted to lines
+// and columns afterwards.
+func jsonPositions(source []byte) (Positions, error) {
+	dec := json.NewDecoder(bytes.NewReader(source))
+	offsets := make(map[string]int64)
+	if err := walkJSONPositions(dec, source, Location{}, offsets); err != nil {
+		return nil, err
+	}
+	lines := []int{0}
+	for idx, b := range source {
+		if b == '\n' {
+			lines = append(lines, idx+1)
+		}
+	}
+	positions := make(Positions, len(offsets))
+	for pointer, offset := range offsets {
+		line := sort.Search(len(lines), func(i int) bool { return int64(lines[i]) > offset }) - 1
+		positions[pointer] = Position{
+			Line:   line + 1,
+			Column: utf8.RuneCount(source[lines[line]:offset]) + 1,
+		}
+	}
+	return positions, nil
+}
+
+func walkJSONPositions(dec *json.Decoder, source []byte, loc Location, offsets map[string]int64) error {
+	offsets[loc.Pointer()] = skipJSONSeparators(source, dec.InputOffset())
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := walkJSONPositions(dec, source, loc.Child(fmt.Sprint(key)), offsets); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for idx := 0; dec.More(); idx++ {
+			if err := walkJSONPositions(dec, source, loc.Child(idx), offsets); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+// skipJSONSeparators moves an offset past the whitespace, colons and commas
+// that the decoder leaves in front of the next value.
+func skipJSONSeparators(source []byte, offset int64) int64 {
+	for offset < int64(len(source)) {
+		switch source[offset] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PositionTestCase struct {
+	Filename  string
+	Format    Format
+	Path      string
+	Locations []string
+}
+
+func (ptc PositionTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(ptc.Filename, ptc.Format)
+	assert.NoError(t, err, ptc.Filename)
+
+	results, err := EvaluateResults(doc.Data, ptc.Path)
+	assert.NoError(t, err, ptc.Path)
+	locations := make([]string, 0, len(results))
+	for _, result := range results {
+		locations = append(locations, doc.Locate(result.Location).String())
+	}
+	assert.ElementsMatch(t, ptc.Locations, locations, ptc.Path)
+}
+
+var PositionTestCases = []PositionTestCase{
+	{
+		Filename:  `test_data/test.yaml`,
+		Format:    FormatYAML,
+		Path:      `.`,
+		Locations: []string{`test_data/test.yaml:1:1`},
+	},
+	{
+		Filename:  `test_data/test.yaml`,
+		Format:    FormatYAML,
+		Path:      `animals.vertebrates.mammals[2]`,
+		Locations: []string{`test_data/test.yaml:13:9`},
+	},
+	{
+		Filename:  `test_data/test.json`,
+		Format:    FormatJSON,
+		Path:      `animals.vertebrates.mammals[2]`,
+		Locations: []string{`test_data/test.json:16:9`},
+	},
+	{
+		Filename:  `test_data/test.json`,
+		Format:    FormatJSON,
+		Path:      `minerals[*]`,
+		Locations: []string{`test_data/test.json:26:16`, `test_data/test.json:31:20`, `test_data/test.json:36:20`},
+	},
+	{
+		Filename:  `test_data/contacts_overlay.json`,
+		Format:    FormatJSON,
+		Path:      `contacts[name == "Carol"].phones[0].type`,
+		Locations: []string{`test_data/contacts_overlay.json:12:19`},
+	},
+	{
+		Filename:  `test_data/contacts.yaml`,
+		Format:    FormatYAML,
+		Path:      `contacts[*].phones[type == "mobile"].number`,
+		Locations: []string{`test_data/contacts.yaml:6:17`},
+	},
+	{
+		Filename:  `test_data/contacts.yaml`,
+		Format:    FormatYAML,
+		Path:      `contacts.flatten().name`,
+		Locations: []string{`test_data/contacts.yaml:2:11`, `test_data/contacts.yaml:9:11`},
+	},
+}
+
+func TestPosition(t *testing.T) {
+	for _, tc := range PositionTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestTemplatePos(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(doc.Data, `minerals.igneous[*]`)
+	assert.NoError(t, err)
+
+	tmplt, err := GetTemplate(`{{ pos }} {{ . }};`)
+	assert.NoError(t, err)
+	funcs, values := ResultFuncs(doc, results)
+	buff := new(bytes.Buffer)
+	assert.NoError(t, tmplt.Funcs(funcs).Execute(buff, values))
+	assert.Equal(t, `test_data/test.yaml:42:7 obsidian;test_data/test.yaml:43:7 granite;test_data/test.yaml:44:7 basalt;`, buff.String())
+
+	tmplt, err = GetTemplate(`{{ pos }}`)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, tmplt.Execute(buff, Values(results)), `pos is only available when rendering search results`)
+
+	doc.Patched = true
+	_, ok := doc.Position(results[0].Location)
+	assert.False(t, ok)
+	funcs, values = ResultFuncs(doc, results)
+	assert.ErrorContains(t, tmplt.Funcs(funcs).Execute(buff, values), `pos can't be used with --patch`)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+//go:embed usage_repl.txt
+var REPLUsage string
+
+// REPL runs search paths against a document that has only been parsed once.
+type REPL struct {
+	Doc *Document
+	// Template renders the results if it is set. Otherwise, each result is
+	// written out in Format.
+	Template string
+	Format   Format
+	History  []string
+
+	out   io.Writer
+	tmplt *template.Template
+}
+
+// NewREPL starts a REPL that writes to out and renders results in the
+// document's own format.
+func NewREPL(doc *Document, out io.Writer) *REPL {
+	return &REPL{
+		Doc:    doc,
+		Format: doc.Format,
+		out:    out,
+	}
+}
+
+// Exec runs one line of input. It reports false when the user asks to quit.
+func (r *REPL) Exec(line string) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == `` {
+		return true, nil
+	}
+	r.History = append(r.History, line)
+	if !strings.HasPrefix(line, `:`) {
+		return true, r.search(line)
+	}
+	command, arg, _ := strings.Cut(line, ` `)
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case `:quit`, `:q`, `:exit`:
+		return false, nil
+	case `:help`, `:h`, `:?`:
+		_, err := io.WriteString(r.out, REPLUsage)
+		return true, err
+	case `:history`:
+		for idx, entry := range r.History[:len(r.History)-1] {
+			fmt.Fprintf(r.out, "%4d  %s\n", idx+1, entry)
+		}
+		return true, nil
+	case `:template`, `:t`:
+		if arg == `` {
+			r.Template, r.tmplt = ``, nil
+			return true, nil
+		}
+		tmplt, err := GetTemplate(arg)
+		if err != nil {
+			return true, err
+		}
+		r.Template, r.tmplt = arg, tmplt
+		return true, nil
+	case `:format`, `:f`:
+		if arg == `` {
+			_, err := fmt.Fprintln(r.out, formatName(r.Format))
+			return true, err
+		}
+		format := LookupFormat(arg)
+		if format == FormatUnknown {
+			return true, fmt.Errorf(`unknown format %q; use yaml or json`, arg)
+		}
+		r.Format = format
+		return true, nil
+	default:
+		return true, fmt.Errorf(`unknown command %q; type :help for a list`, command)
+	}
+}
+
+func (r *REPL) search(path string) error {
+	results, err := EvaluateResults(r.Doc.Data, path)
+	if err != nil {
+		return err
+	}
+	if r.tmplt != nil {
+		buff := new(strings.Builder)
+		funcs, values := ResultFuncs(r.Doc, results)
+		err := TemplateSandbox.Run(buff, r.tmplt, funcs, func(w io.Writer, tmplt *template.Template) error {
+			return ExecuteTemplate(w, tmplt, TemplateLayout.Data(r.Doc, values))
+		})
+		if err != nil {
+			return &TemplateError{Name: r.tmplt.Name(), Err: err}
+		}
+		text := buff.String()
+		if text != `` && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		_, err = io.WriteString(r.out, text)
+		return err
+	}
+	for _, result := range results {
+		out, err := Marshal(result.Value, r.Format)
+		if err != nil {
+			return err
+		}
+		if _, err := r.out.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatName(format Format) string {
+	switch format {
+	case FormatJSON:
+		return `json`
+	case FormatYAML:
+		return `yaml`
+	default:
+		return `unknown`
+	}
+}
+
+// RunREPL reads lines from in until it runs out or the user quits. If in is
+// a terminal, it gets line editing, history and tab completion.
+func RunREPL(doc *Document, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		repl := NewREPL(doc, out)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			more, err := repl.Exec(scanner.Text())
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+			if !more {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf(`could not set up the terminal: %w`, err)
+	}
+	defer term.Restore(fd, state)
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, `stool> `)
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		terminal.SetSize(width, height)
+	}
+	completer := &memberCompleter{data: doc.Data}
+	terminal.AutoCompleteCallback = completer.complete
+
+	repl := NewREPL(doc, terminal)
+	fmt.Fprintf(terminal, "Loaded %s. Type :help for help.\n", doc.Filename)
+	for {
+		line, err := terminal.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		more, err := repl.Exec(line)
+		if err != nil {
+			fmt.Fprintln(terminal, err)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// CompleteMember lists the ways the member name at the end of line could be
+// finished, using the keys of whatever the rest of the line finds. Each
+// candidate is the whole line with the member filled in.
+func CompleteMember(data any, line string) []string {
+	runes := []rune(line)
+	start := len(runes)
+	for start > 0 && isMemberRune(runes[start-1]) {
+		start--
+	}
+	base, fragment := string(runes[:start]), string(runes[start:])
+	if base != `` && !strings.HasSuffix(base, `.`) {
+		return nil
+	}
+	results, err := EvaluateResults(data, strings.TrimSuffix(base, `.`))
+	if err != nil {
+		return nil
+	}
+	candidates := make([]string, 0)
+	for _, key := range availableKeys(results) {
+		if !strings.HasPrefix(key, fragment) {
+			continue
+		}
+		if isPlainMember(key) {
+			candidates = append(candidates, base+key)
+		} else {
+			candidates = append(candidates, strings.TrimSuffix(base, `.`)+quoteMember(key))
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func isMemberRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// memberCompleter completes member names when tab is pressed. The first tab
+// fills in as much as all the candidates share, and each tab after that
+// steps through the candidates.
+type memberCompleter struct {
+	data       any
+	last       string
+	candidates []string
+	next       int
+}
+
+func (mc *memberCompleter) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return ``, 0, false
+	}
+	prefix, suffix := line[:pos], line[pos:]
+	if prefix != mc.last || len(mc.candidates) < 2 {
+		mc.candidates = CompleteMember(mc.data, prefix)
+		mc.next = 0
+		if len(mc.candidates) == 0 {
+			return ``, 0, false
+		}
+		if common := commonPrefix(mc.candidates); len(mc.candidates) > 1 && len(common) > len(prefix) {
+			mc.last, mc.next = common, -1
+			return common + suffix, len(common), true
+		}
+	} else {
+		mc.next = (mc.next + 1) % len(mc.candidates)
+	}
+	mc.last = mc.candidates[mc.next]
+	return mc.last + suffix, len(mc.last), true
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ``
+	}
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type REPLTestCase struct {
+	Lines         []string
+	Expected      string
+	ExpectedError string
+}
+
+func (rtc REPLTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	buff := new(bytes.Buffer)
+	repl := NewREPL(doc, buff)
+	for _, line := range rtc.Lines {
+		_, err = repl.Exec(line)
+	}
+	if rtc.ExpectedError != `` {
+		assert.ErrorContains(t, err, rtc.ExpectedError, rtc.Lines)
+		return
+	}
+	assert.NoError(t, err, rtc.Lines)
+	assert.Equal(t, rtc.Expected, buff.String(), rtc.Lines)
+}
+
+var REPLTestCases = []REPLTestCase{
+	{
+		Lines:    []string{`minerals.igneous`},
+		Expected: "- obsidian\n- granite\n- basalt\n",
+	},
+	{
+		Lines:    []string{`:format json`, `minerals.igneous[1]`, `:format`},
+		Expected: "\"granite\"\njson\n",
+	},
+	{
+		Lines:    []string{`:template {{ path }}={{ . }};`, `minerals.igneous[*]`, `:template`, `minerals.igneous[2]`},
+		Expected: "minerals.igneous[0]=obsidian;minerals.igneous[1]=granite;minerals.igneous[2]=basalt;\nbasalt\n",
+	},
+	{
+		Lines:    []string{`minerals.igneous[0]`, `:history`},
+		Expected: "obsidian\n   1  minerals.igneous[0]\n",
+	},
+	{
+		Lines:         []string{`minerals.lenght()`},
+		ExpectedError: `did you mean "length()"?`,
+	},
+	{
+		Lines:         []string{`:template {{ .x`},
+		ExpectedError: `template: cmdline`,
+	},
+	{
+		Lines:         []string{`:format toml`},
+		ExpectedError: `unknown format "toml"`,
+	},
+}
+
+func TestREPL(t *testing.T) {
+	for _, tc := range REPLTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestREPLQuit(t *testing.T) {
+	repl := NewREPL(&Document{Data: NewMap()}, new(bytes.Buffer))
+	more, err := repl.Exec(`:quit`)
+	assert.NoError(t, err)
+	assert.False(t, more)
+}
+
+type CompleteTestCase struct {
+	Line     string
+	Expected []string
+}
+
+var CompleteTestCases = []CompleteTestCase{
+	{Line: ``, Expected: []string{`animals`, `meta`, `minerals`, `vegetables`}},
+	{Line: `m`, Expected: []string{`meta`, `minerals`}},
+	{Line: `animals.vertebrates.`, Expected: []string{`animals.vertebrates.mammals`, `animals.vertebrates.reptiles`}},
+	{Line: `animals.in`, Expected: []string{`animals.invertebrates`}},
+	{Line: `animals.x`, Expected: []string{}},
+	{Line: `animals[*].`, Expected: []string{`animals[*].insects`, `animals[*].mammals`, `animals[*].mollusks`, `animals[*].reptiles`}},
+	{Line: `animals[*]`, Expected: []string(nil)},
+	{Line: `{"a b": 1}.`, Expected: []string(nil)},
+}
+
+func TestCompleteMember(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	for _, tc := range CompleteTestCases {
+		assert.Equal(t, tc.Expected, CompleteMember(doc.Data, tc.Line), tc.Line)
+	}
+	assert.Equal(t, []string{`["a b"]`, `['say "hi"']`}, CompleteMember(Normalize(map[string]any{`a b`: 1, `say "hi"`: 2}), ``))
+}
+
+func TestMemberCompleter(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	mc := &memberCompleter{data: doc.Data}
+	line, pos, ok := mc.complete(`m`, 1, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, `meta`, line[:pos])
+	line, _, _ = mc.complete(line, len(line), '\t')
+	assert.Equal(t, `minerals`, line)
+	line, pos, ok = mc.complete(`minerals.s`, 10, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, `minerals.sedimentary`, line)
+	assert.Equal(t, len(line), pos)
+	line, _, _ = mc.complete(`animals.vertebrates.`, 20, '\t')
+	assert.Equal(t, `animals.vertebrates.mammals`, line)
+	line, _, _ = mc.complete(line, len(line), '\t')
+	assert.Equal(t, `animals.vertebrates.reptiles`, line)
+	line, _, _ = mc.complete(line, len(line), '\t')
+	assert.Equal(t, `animals.vertebrates.mammals`, line)
+	_, _, ok = mc.complete(`x`, 1, 'x')
+	assert.False(t, ok)
+}
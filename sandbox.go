@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/masterminds/sprig"
+)
+
+// Sandbox restricts what a template can do, so that templates written by
+// someone else can be rendered without letting them read the environment
+// or take up unlimited time and output.
+type Sandbox struct {
+	// Enabled turns the restrictions on. The limits below only apply when
+	// it is set.
+	Enabled bool
+	// Timeout is how long rendering may take, or 0 for no limit. A render
+	// that takes too long is stopped at its next function call or write;
+	// until then it goes on in the background.
+	Timeout time.Duration
+	// MaxOutput is the most bytes rendering may write, or 0 for no limit.
+	// It also limits the size of each string, list or map a function
+	// makes, which could otherwise use up memory without writing
+	// anything. until, untilStep and repeat are checked before they make
+	// anything. It doesn't limit how many values a template keeps at
+	// once, so it doesn't limit memory as a whole.
+	MaxOutput int
+}
+
+// DefaultSandbox is off, but has limits that suit most templates once it
+// is turned on.
+var DefaultSandbox = Sandbox{
+	Timeout:   5 * time.Second,
+	MaxOutput: 1 << 20,
+}
+
+// TemplateSandbox is the Sandbox set on the command line.
+var TemplateSandbox = DefaultSandbox
+
+// defineSandboxFlags registers the options that set TemplateSandbox.
+func defineSandboxFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&TemplateSandbox.Enabled, `safe-template`, TemplateSandbox.Enabled, `render untrusted templates, without environment, network or random functions, and with limits`)
+	fs.DurationVar(&TemplateSandbox.Timeout, `template-timeout`, TemplateSandbox.Timeout, `how long a template may take with --safe-template, or 0 for no limit; it is stopped at its next function call or write`)
+	fs.IntVar(&TemplateSandbox.MaxOutput, `template-max-output`, TemplateSandbox.MaxOutput, `the most bytes a template may write with --safe-template, and the biggest string, list or map a function may make, or 0 for no limit`)
+}
+
+// Check reports settings that can't be used.
+func (s Sandbox) Check() error {
+	if s.Timeout < 0 {
+		return fmt.Errorf(`the template timeout can't be negative`)
+	}
+	if s.MaxOutput < 0 {
+		return fmt.Errorf(`the template output limit can't be negative`)
+	}
+	return nil
+}
+
+// unsafeTemplateFuncs are the template functions that can't be used in a
+// Sandbox, with the reason why.
+var unsafeTemplateFuncs = map[string]string{
+	`env`:               `it reads the environment`,
+	`expandenv`:         `it reads the environment`,
+	`getHostByName`:     `it looks up names on the network`,
+	`now`:               `it reads the clock`,
+	`ago`:               `it reads the clock`,
+	`randAlpha`:         `it is random`,
+	`randAlphaNum`:      `it is random`,
+	`randAscii`:         `it is random`,
+	`randNumeric`:       `it is random`,
+	`shuffle`:           `it is random`,
+	`uuidv4`:            `it is random`,
+	`encryptAES`:        `it is random`,
+	`genPrivateKey`:     `it generates random keys`,
+	`genCA`:             `it generates random keys`,
+	`genSelfSignedCert`: `it generates random keys`,
+	`genSignedCert`:     `it generates random keys`,
+}
+
+// Funcs are the functions that replace FuncMap's in a Sandbox. The
+// unsafe functions fail if they are somehow called, and the functions
+// that make lists, maps and strings are limited to MaxOutput. Once stop is
+// set, every function fails, so that a render that has run out of time
+// stops at its next call. stop may be nil.
+func (s Sandbox) Funcs(stop *int32) template.FuncMap {
+	fm := FuncMap()
+	fm[`print`] = fmt.Sprint
+	fm[`printf`] = fmt.Sprintf
+	fm[`println`] = fmt.Sprintln
+	for name, reason := range unsafeTemplateFuncs {
+		name, reason := name, reason
+		fm[name] = func(...any) (any, error) {
+			return nil, fmt.Errorf(`function %q is not allowed with --safe-template, because %s`, name, reason)
+		}
+	}
+	sprigFuncs := sprig.TxtFuncMap()
+	untilStep := sprigFuncs[`untilStep`].(func(int, int, int) []int)
+	repeat := sprigFuncs[`repeat`].(func(int, string) string)
+	fm[`until`] = func(count int) ([]int, error) {
+		step := 1
+		if count < 0 {
+			step = -1
+		}
+		if err := s.checkLength(`until`, rangeLength(0, count, step), `numbers`); err != nil {
+			return nil, err
+		}
+		return untilStep(0, count, step), nil
+	}
+	fm[`untilStep`] = func(start, stop, step int) ([]int, error) {
+		if err := s.checkLength(`untilStep`, rangeLength(start, stop, step), `numbers`); err != nil {
+			return nil, err
+		}
+		return untilStep(start, stop, step), nil
+	}
+	fm[`repeat`] = func(count int, str string) (string, error) {
+		if count > 0 && len(str) > 0 {
+			if err := s.checkLength(`repeat`, uint64(count)*uint64(len(str)), `bytes`); err != nil {
+				return ``, err
+			}
+		}
+		return repeat(count, str), nil
+	}
+	return s.limitFuncs(fm, stop)
+}
+
+// limitFuncs applies limit to each of the functions in fm.
+func (s Sandbox) limitFuncs(fm template.FuncMap, stop *int32) template.FuncMap {
+	limited := make(template.FuncMap, len(fm))
+	for name, fn := range fm {
+		limited[name] = s.limit(name, fn, stop)
+	}
+	return limited
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// limit wraps a template function so that it fails once stop is set, and,
+// if it can return a string, list or map, if what it returns is bigger
+// than MaxOutput. Each value a function is given has already been
+// checked, so no single call can make much more than the limit.
+func (s Sandbox) limit(name string, fn any, stop *int32) any {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	in := make([]reflect.Type, t.NumIn())
+	for idx := range in {
+		in[idx] = t.In(idx)
+	}
+	out := []reflect.Type{t.Out(0), errorType}
+	limited := reflect.MakeFunc(reflect.FuncOf(in, out, t.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		if stop != nil && atomic.LoadInt32(stop) != 0 {
+			err := timeoutError(s.Timeout)
+			return []reflect.Value{reflect.Zero(t.Out(0)), reflect.ValueOf(&err).Elem()}
+		}
+		var results []reflect.Value
+		if t.IsVariadic() {
+			results = v.CallSlice(args)
+		} else {
+			results = v.Call(args)
+		}
+		if len(results) == 1 {
+			results = append(results, reflect.Zero(errorType))
+		}
+		if !results[1].IsNil() {
+			return results
+		}
+		result := results[0]
+		if result.Kind() == reflect.Interface {
+			result = result.Elem()
+		}
+		var err error
+		switch result.Kind() {
+		case reflect.String:
+			err = s.checkLength(name, uint64(result.Len()), `bytes`)
+		case reflect.Slice, reflect.Map:
+			err = s.checkLength(name, uint64(result.Len()), `items`)
+		}
+		if err != nil {
+			return []reflect.Value{reflect.Zero(t.Out(0)), reflect.ValueOf(&err).Elem()}
+		}
+		return results
+	})
+	return limited.Interface()
+}
+
+func (s Sandbox) checkLength(name string, length uint64, unit string) error {
+	if s.MaxOutput > 0 && length > uint64(s.MaxOutput) {
+		return fmt.Errorf(`%s would make %d %s, more than the --template-max-output limit of %d`, name, length, unit, s.MaxOutput)
+	}
+	return nil
+}
+
+// rangeLength is how many numbers untilStep would make, worked out
+// without overflowing.
+func rangeLength(start, stop, step int) uint64 {
+	var distance, stride uint64
+	switch {
+	case stop > start && step > 0:
+		distance, stride = uint64(stop-start), uint64(step)
+	case stop < start && step < 0:
+		distance, stride = uint64(start-stop), uint64(-step)
+	default:
+		return 0
+	}
+	length := distance / stride
+	if distance%stride != 0 {
+		length++
+	}
+	return length
+}
+
+// Restrict makes t safe to render in the Sandbox. It fails if any of the
+// templates in t use an unsafe function, even in a part that might not be
+// rendered, and swaps in the limited versions of the others.
+func (s Sandbox) Restrict(t *template.Template) error {
+	if !s.Enabled {
+		return nil
+	}
+	templates := t.Templates()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+	for _, defined := range templates {
+		if defined.Tree == nil {
+			continue
+		}
+		var err error
+		walkTemplate(defined.Tree.Root, func(node parse.Node) {
+			ident, ok := node.(*parse.IdentifierNode)
+			if !ok || err != nil {
+				return
+			}
+			if reason, ok := unsafeTemplateFuncs[ident.Ident]; ok {
+				location, _ := defined.Tree.ErrorContext(node)
+				err = fmt.Errorf(`template: %s: function %q is not allowed with --safe-template, because %s`, location, ident.Ident, reason)
+			}
+		})
+		if err != nil {
+			return &TemplateError{Name: defined.Name(), Err: err}
+		}
+	}
+	t.Funcs(s.Funcs(nil))
+	return nil
+}
+
+// Run binds funcs, the functions made by ResultFuncs for this rendering,
+// to t, and calls render, which should write t's output to the writer it
+// is given. In the Sandbox, render is given a copy of t whose functions
+// stop working if it runs out of time, and the output is only passed on to
+// w if it is within MaxOutput and render finishes within Timeout.
+func (s Sandbox) Run(w io.Writer, t *template.Template, funcs template.FuncMap, render func(io.Writer, *template.Template) error) error {
+	if !s.Enabled {
+		t.Funcs(funcs)
+		return render(w, t)
+	}
+	t, err := t.Clone()
+	if err != nil {
+		return err
+	}
+	stop := new(int32)
+	t.Funcs(s.Funcs(stop)).Funcs(s.limitFuncs(funcs, stop))
+	lw := &limitedWriter{max: s.MaxOutput, timeout: s.Timeout, stop: stop}
+	done := make(chan error, 1)
+	go func() {
+		done <- render(lw, t)
+	}()
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timer := time.NewTimer(s.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(lw.buff.Bytes())
+		return err
+	case <-timeout:
+		// Templates can't be stopped from outside, so the render is
+		// abandoned, and fails at its next function call or write. A loop
+		// that makes neither runs on until it ends.
+		atomic.StoreInt32(stop, 1)
+		return timeoutError(s.Timeout)
+	}
+}
+
+func timeoutError(timeout time.Duration) error {
+	return fmt.Errorf(`the template took longer than the --template-timeout of %s`, timeout)
+}
+
+// limitedWriter collects a template's output, and fails once there is too
+// much of it, or the render has been stopped.
+type limitedWriter struct {
+	buff    bytes.Buffer
+	max     int
+	timeout time.Duration
+	stop    *int32
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(lw.stop) != 0 {
+		return 0, timeoutError(lw.timeout)
+	}
+	if lw.max > 0 && lw.buff.Len()+len(p) > lw.max {
+		return 0, fmt.Errorf(`the template wrote more than the --template-max-output limit of %d bytes`, lw.max)
+	}
+	return lw.buff.Write(p)
+}
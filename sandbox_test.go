@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type SandboxTestCase struct {
+	Sandbox       Sandbox
+	Layout        Layout
+	Template      string
+	Expected      string
+	ExpectedError string
+}
+
+func (stc SandboxTestCase) Test(t *testing.T) {
+	t.Helper()
+	tmplt, err := stc.Layout.Parse(`cmdline`, stc.Template)
+	if !assert.NoError(t, err, stc.Template) {
+		return
+	}
+	buff := new(bytes.Buffer)
+	err = stc.Sandbox.Restrict(tmplt)
+	if err == nil {
+		err = stc.Sandbox.Run(buff, tmplt, nil, func(w io.Writer, tmplt *template.Template) error {
+			return ExecuteTemplate(w, tmplt, []any{`a`, `b`})
+		})
+	}
+	if stc.ExpectedError != `` {
+		assert.ErrorContains(t, err, stc.ExpectedError, stc.Template)
+		assert.Empty(t, buff.String(), stc.Template)
+		return
+	}
+	assert.NoError(t, err, stc.Template)
+	assert.Equal(t, stc.Expected, buff.String(), stc.Template)
+}
+
+var safeSandbox = Sandbox{Enabled: true, Timeout: time.Second, MaxOutput: 100}
+
+var SandboxTestCases = []SandboxTestCase{
+	{
+		Sandbox:  Sandbox{},
+		Template: `{{ if eq . "z" }}{{ env "HOME" }}{{ end }}{{ repeat 200 "-" | len }} `,
+		Expected: `200 200 `,
+	},
+	{
+		Sandbox:  safeSandbox,
+		Template: `{{ . | upper }}{{ untilStep 5 0 -2 | join "," }}{{ repeat 2 "-" }}`,
+		Expected: `A5,3,1--B5,3,1--`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ if eq . "z" }}{{ env "HOME" }}{{ end }}`,
+		ExpectedError: `cmdline:1:20: function "env" is not allowed with --safe-template, because it reads the environment`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Layout:        Layout{Header: `{{ with 1 }}{{ uuidv4 | quote }}{{ end }}`},
+		Template:      `{{ . }}`,
+		ExpectedError: `--header:1:15: function "uuidv4" is not allowed`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ repeat 200 "-" | len }}`,
+		ExpectedError: `repeat would make 200 bytes, more than the --template-max-output limit of 100`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ range until 1000000000 }}{{ end }}`,
+		ExpectedError: `until would make 1000000000 numbers`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ range untilStep -9223372036854775807 9223372036854775807 1 }}{{ end }}`,
+		ExpectedError: `untilStep would make 18446744073709551614 numbers`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ $s := "ab" }}{{ range until 10 }}{{ $s = cat $s $s }}{{ end }}`,
+		ExpectedError: `cat would make 191 bytes, more than the --template-max-output limit of 100`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ $s := "ab" }}{{ range until 10 }}{{ $s = printf "%s%s" $s $s }}{{ end }}`,
+		ExpectedError: `printf would make 128 bytes`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ $l := list 1 }}{{ range until 10 }}{{ $l = concat $l $l }}{{ end }}`,
+		ExpectedError: `concat would make 128 items`,
+	},
+	{
+		Sandbox:  safeSandbox,
+		Template: `{{ $s := "ab" }}{{ range until 3 }}{{ $s = printf "%s%s" $s $s }}{{ end }}{{ len $s }}{{ list 1 2 | len }}{{ print . }}`,
+		Expected: `162a162b`,
+	},
+	{
+		Sandbox:       safeSandbox,
+		Template:      `{{ range until 40 }}{{ . }}{{ end }}`,
+		ExpectedError: `the template wrote more than the --template-max-output limit of 100 bytes`,
+	},
+	{
+		Sandbox:       Sandbox{Enabled: true, Timeout: 50 * time.Millisecond},
+		Template:      `{{ range until 100000 }}{{ range until 100000 }}.{{ end }}{{ end }}`,
+		ExpectedError: `the template took longer than the --template-timeout of 50ms`,
+	},
+}
+
+func TestSandbox(t *testing.T) {
+	for _, tc := range SandboxTestCases {
+		tc.Test(t)
+	}
+	assert.NoError(t, DefaultSandbox.Check())
+	assert.Error(t, Sandbox{Timeout: -time.Second}.Check())
+	assert.Error(t, Sandbox{MaxOutput: -1}.Check())
+}
+
+func TestSandboxStopsAbandonedRenders(t *testing.T) {
+	tmplt, err := GetTemplate(`{{ range until 100000 }}{{ range until 100000 }}{{ $x := add1 . }}{{ end }}{{ end }}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sandbox := Sandbox{Enabled: true, Timeout: 20 * time.Millisecond}
+	finished := make(chan error, 1)
+	err = sandbox.Run(io.Discard, tmplt, nil, func(w io.Writer, tmplt *template.Template) error {
+		err := ExecuteTemplate(w, tmplt, []any{`a`})
+		finished <- err
+		return err
+	})
+	assert.EqualError(t, err, `the template took longer than the --template-timeout of 20ms`)
+	select {
+	case err := <-finished:
+		assert.ErrorContains(t, err, `error calling add1: the template took longer`)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, `the abandoned render is still running`)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+//go:embed usage_set.txt
+var SetUsage string
+
+// lastChunk splits a search path into the path to the last part and the
+// last part itself, skipping dots.
+func lastChunk(path string) (string, string, PathChunkType, error) {
+	if err := CheckPath(path); err != nil {
+		return ``, ``, PCTEmpty, err
+	}
+	p := NewPath(path)
+	runes := p.RuneArray()
+	var (
+		offset   int
+		last     string
+		lastType = PCTEmpty
+	)
+	for {
+		start := len(runes) - len(*p)
+		chunk, chunkType, _ := p.chunk()
+		switch chunkType {
+		case PCTEmpty:
+			return string(runes[:offset]), last, lastType, nil
+		case PCTDot:
+			continue
+		}
+		offset, last, lastType = start, chunk, chunkType
+	}
+}
+
+// Set replaces every value the search path finds with value. If the path
+// finds nothing but ends with a member name, the member is added to each map
+// the rest of the path finds. data is not modified; the changed copy is
+// returned.
+func Set(data any, path string, value any) (any, error) {
+	parent, member, chunkType, err := lastChunk(path)
+	if err != nil {
+		return nil, err
+	}
+	if chunkType == PCTFunction {
+		return nil, fmt.Errorf(`the search path must end at a value in the document, not at %s()`, member)
+	}
+	data = copyValue(data)
+	results, err := EvaluateResults(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 && chunkType == PCTMember {
+		parents, err := EvaluateStrict(data, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range parents {
+			if _, ok := result.Value.(*Map); ok {
+				results = append(results, result.child(nil, member))
+			}
+		}
+		if len(results) == 0 {
+			return nil, fmt.Errorf(`cannot add %q, because %q found no maps`, member, parent)
+		}
+	}
+	if len(results) == 0 {
+		return nil, &NoResultsError{Path: path, Chunk: path}
+	}
+	for _, result := range results {
+		data, err = setAt(data, result.Location, Normalize(value))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// setAt stores value at a location, replacing whatever is there.
+func setAt(data any, loc Location, value any) (any, error) {
+	if loc.Derived() {
+		return nil, fmt.Errorf(`cannot set %s, because it was made by a search path function, not found in the document`, loc)
+	}
+	if len(loc) == 0 {
+		return value, nil
+	}
+	tokens := make([]string, len(loc))
+	for idx, key := range loc {
+		tokens[idx] = fmt.Sprint(key)
+	}
+	return patchUpdate(data, tokens, 0, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case *Map:
+			c.Set(token, value)
+			return c, nil
+		case []any:
+			idx, err := patchIndex(token, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: %w`, loc, err)
+			}
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf(`%s: cannot set a value in a %T`, loc, container)
+		}
+	})
+}
+
+func runSet(args []string) error {
+	var (
+		inputFormat string
+		outputFile  = `-`
+		inPlace     bool
+		asString    bool
+	)
+	flags := newFlagSet(`set`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input file; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.StringVar(&outputFile, `out`, outputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(flags, `out`, `o`)
+	flags.BoolVar(&inPlace, `in-place`, inPlace, `write the changed document back to the input file`)
+	aliasFlag(flags, `in-place`, `w`)
+	flags.BoolVar(&asString, `string`, asString, `use the value as a string instead of reading it as YAML`)
+	defineStyleFlags(flags)
+	if err := parseFlags(flags, args, SetUsage); err != nil {
+		return err
+	}
+
+	if err := OutputStyle.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+
+	if flags.NArg() != 3 {
+		return &UsageError{Err: fmt.Errorf(`set needs a file, a search path and a value`)}
+	}
+	filename, path, text := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+	if inPlace {
+		if filename == `-` {
+			return &UsageError{Err: fmt.Errorf(`--in-place needs a file, not STDIN`)}
+		}
+		outputFile = filename
+	}
+	var value any = text
+	if !asString {
+		var err error
+		if value, err = decodeYAML([]byte(text)); err != nil {
+			return fmt.Errorf(`could not read the value %q; use --string to set it as text: %w`, text, err)
+		}
+	}
+	doc, err := ParseDocument(filename, LookupFormat(inputFormat))
+	if err != nil {
+		return err
+	}
+	data, err := Set(doc.Data, path, value)
+	if err != nil {
+		return err
+	}
+	out, err := Marshal(data, doc.Format)
+	if err != nil {
+		return fmt.Errorf(`could not render %q: %w`, filename, err)
+	}
+	if outputFile == `-` {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outputFile); err == nil {
+		mode = info.Mode()
+	}
+	return ioutil.WriteFile(outputFile, out, mode)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type SetTestCase struct {
+	Path            string
+	Value           any
+	CheckPath       string
+	ExpectedResults []any
+	ExpectedError   string
+}
+
+func (stc SetTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(`test_data/test.json`, FormatJSON)
+	assert.NoError(t, err)
+	before, err := Marshal(doc.Data, FormatJSON)
+	assert.NoError(t, err)
+
+	data, err := Set(doc.Data, stc.Path, stc.Value)
+	if stc.ExpectedError != `` {
+		assert.EqualError(t, err, stc.ExpectedError, stc.Path)
+		return
+	}
+	assert.NoError(t, err, stc.Path)
+	results, err := Evaluate(data, stc.CheckPath)
+	assert.NoError(t, err, stc.CheckPath)
+	assert.Equal(t, stc.ExpectedResults, results, stc.Path)
+
+	after, err := Marshal(doc.Data, FormatJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after), `the original document was changed`)
+}
+
+var SetTestCases = []SetTestCase{
+	{
+		Path:            `animals.vertebrates.mammals[1]`,
+		Value:           `mole`,
+		CheckPath:       `animals.vertebrates.mammals`,
+		ExpectedResults: []any{[]any{`horse`, `mole`, `cat`}},
+	},
+	{
+		Path:            `minerals.igneous`,
+		Value:           []any{`pumice`},
+		CheckPath:       `minerals.igneous`,
+		ExpectedResults: []any{[]any{`pumice`}},
+	},
+	{
+		Path:            `animals.vertebrates.birds`,
+		Value:           []any{`robin`},
+		CheckPath:       `animals.vertebrates.birds`,
+		ExpectedResults: []any{[]any{`robin`}},
+	},
+	{
+		Path:            `minerals[*][0]`,
+		Value:           `gone`,
+		CheckPath:       `minerals.igneous[0]`,
+		ExpectedResults: []any{`gone`},
+	},
+	{
+		Path:            `.`,
+		Value:           42,
+		CheckPath:       `.`,
+		ExpectedResults: []any{42},
+	},
+	{
+		Path:          `animals.keys()`,
+		Value:         1,
+		ExpectedError: `the search path must end at a value in the document, not at keys()`,
+	},
+	{
+		Path:          `animals.keys()[0]`,
+		Value:         1,
+		ExpectedError: `cannot set animals.keys()[0], because it was made by a search path function, not found in the document`,
+	},
+	{
+		Path:          `minerals.igneous[*].results()[0]`,
+		Value:         1,
+		ExpectedError: `cannot set results()[0], because it was made by a search path function, not found in the document`,
+	},
+	{
+		Path:          `animals.json().jsoneval().extra`,
+		Value:         1,
+		ExpectedError: `cannot set animals.json().jsoneval().extra, because it was made by a search path function, not found in the document`,
+	},
+	{
+		Path:          `animals.vertebrates.mammals.name`,
+		Value:         1,
+		ExpectedError: `cannot add "name", because "animals.vertebrates.mammals." found no maps`,
+	},
+}
+
+func TestSet(t *testing.T) {
+	for _, tc := range SetTestCases {
+		tc.Test(t)
+	}
+	_, err := Set(NewMap(), `animals.vertebrates`, 1)
+	var noResults *NoResultsError
+	assert.ErrorAs(t, err, &noResults)
+
+	filename := filepath.Join(t.TempDir(), `config.yml`)
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("port: 80\n"), 0600))
+	assert.NoError(t, os.Chmod(filename, 0600))
+	assert.NoError(t, runSet([]string{`-w`, `-f`, `yaml`, filename, `port`, `8080`}))
+	out, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "port: 8080\n", string(out))
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
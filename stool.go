@@ -3,12 +3,17 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
+	"text/template"
 )
 
 //go:embed usage.txt
@@ -16,71 +21,421 @@ var Usage string
 
 var InputFile string = `-`
 var InputFormat Format = FormatUnknown
+var inputFormatName string = ``
 var OutputFile string = `-`
 var SearchPath string = `.`
 var OutputTemplate string = `{{ . | yaml }}`
-var OutputTemplateFile string = ``
-
-func getOpts() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, Usage, os.Args[0])
-	}
-	flag.StringVar(&InputFile, `in`, InputFile, `the file to read or - for STDIN`)
-	flag.StringVar(&InputFile, `i`, InputFile, `the file to read or - for STDIN`)
-	format := flag.String(`format`, ``, `the format of the input file; yaml|json anything else will try to auto-detect`)
-	flag.StringVar(format, `f`, ``, `the format of the input file; yaml|json anything else will try to auto-detect`)
-	flag.StringVar(&OutputFile, `out`, OutputFile, `the file to write to or - for STDOUT`)
-	flag.StringVar(&OutputFile, `o`, OutputFile, `the file to write to or - for STDOUT`)
-	flag.StringVar(&SearchPath, `search`, SearchPath, `a path to search the input data before rendering`)
-	flag.StringVar(&SearchPath, `s`, SearchPath, `a path to search the input data before rendering`)
-	flag.StringVar(&OutputTemplate, `template`, OutputTemplate, `a go template to use to render the output`)
-	flag.StringVar(&OutputTemplate, `t`, OutputTemplate, `a go template to use to render the output`)
-	flag.StringVar(&OutputTemplateFile, `template-file`, OutputTemplateFile, `read the template from this file instead of the command line`)
-	flag.StringVar(&OutputTemplateFile, `T`, OutputTemplateFile, `read the template from this file instead of the command line`)
-	flag.Parse()
-
-	switch strings.ToLower(*format) {
-	case `json`, `j`, `js`:
-		InputFormat = FormatJSON
-	case `yaml`, `yml`, `y`:
-		InputFormat = FormatYAML
-	}
-	if infile := flag.Arg(0); InputFile == `-` && infile != `` {
+var OutputTemplateFiles stringList
+var PatchFile string = ``
+var WithLocation bool = false
+var PrintPaths bool = false
+var Strict bool = false
+var CheckExitStatus bool = false
+var ErrorFormat string = `text`
+var Interactive bool = false
+var RawOutput bool = false
+var JoinOutput bool = false
+var NullOutput bool = false
+
+// Commands are the subcommands that can be given as the first argument.
+// Names starting with "__" are used by the completion scripts, and aren't
+// listed. It is filled in by init, because the completion command lists
+// the commands, which Go can't allow in the variable's own initializer.
+var Commands map[string]func([]string) error
+
+func init() {
+	Commands = map[string]func([]string) error{
+		`query`:             runQuery,
+		`convert`:           runConvert,
+		`set`:               runSet,
+		`fmt`:               runFmt,
+		`merge`:             runMerge,
+		`diff`:              runDiff,
+		`validate`:          runValidate,
+		`browse`:            runBrowse,
+		`completion`:        runCompletion,
+		`__complete-search`: runCompleteSearch,
+	}
+}
+
+// ExitStatus can be returned by a command to end the program with a specific
+// exit code without reporting an error.
+type ExitStatus int
+
+func (es ExitStatus) Error() string {
+	return fmt.Sprintf(`exit status %d`, int(es))
+}
+
+// The exit statuses stool uses, so that scripts can tell failures apart.
+const (
+	ExitNoResults ExitStatus = 1   // nothing was found, or the last result was false or null
+	ExitUsage     ExitStatus = 2   // the command line could not be understood
+	ExitQuery     ExitStatus = 3   // the search path could not be evaluated
+	ExitParse     ExitStatus = 4   // an input file could not be parsed
+	ExitTemplate  ExitStatus = 5   // the template could not be parsed or rendered
+	ExitIO        ExitStatus = 6   // a file could not be read or written
+	ExitFailure   ExitStatus = 255 // anything else
+)
+
+// exitStatusOf picks the exit status for an error. Errors that don't say
+// what kind of failure they are get the status they were given by
+// withStatus, or the fallback.
+func exitStatusOf(err error, fallback ExitStatus) ExitStatus {
+	var (
+		status    ExitStatus
+		ue        *UsageError
+		pe        *ParseError
+		qse       *QuerySyntaxError
+		ufe       *UnknownFunctionError
+		te        *TemplateError
+		noResults *NoResultsError
+		pathErr   *fs.PathError
+		se        *StatusError
+	)
+	switch {
+	case errors.As(err, &status):
+		return status
+	case errors.As(err, &ue):
+		return ExitUsage
+	case errors.As(err, &noResults):
+		return ExitNoResults
+	case errors.As(err, &pe):
+		return ExitParse
+	case errors.As(err, &qse), errors.As(err, &ufe):
+		return ExitQuery
+	case errors.As(err, &te):
+		return ExitTemplate
+	case errors.As(err, &pathErr):
+		return ExitIO
+	case errors.As(err, &se):
+		return se.Status
+	}
+	return fallback
+}
+
+// fail reports an error and ends the program.
+func fail(err error, fallback ExitStatus) {
+	status := exitStatusOf(err, fallback)
+	if ErrorFormat == `json` {
+		out, _ := json.Marshal(NewErrorReport(err, status))
+		fmt.Fprintf(os.Stderr, "%s\n", out)
+	} else {
+		log.Print(err)
+	}
+	os.Exit(int(status))
+}
+
+// isFalsy reports whether a result should make --exit-status fail.
+func isFalsy(value any) bool {
+	return value == nil || value == false
+}
+
+// defineFlags registers the options for searching a file. They are kept
+// apart from parseQueryOpts so that the completion scripts can list them.
+func defineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&InputFile, `in`, InputFile, `the file to read or - for STDIN`)
+	aliasFlag(fs, `in`, `i`)
+	fs.StringVar(&inputFormatName, `format`, inputFormatName, `the format of the input file; yaml|json anything else will try to auto-detect`)
+	aliasFlag(fs, `format`, `f`)
+	fs.StringVar(&OutputFile, `out`, OutputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(fs, `out`, `o`)
+	fs.StringVar(&SearchPath, `search`, SearchPath, `a path to search the input data before rendering`)
+	aliasFlag(fs, `search`, `s`)
+	fs.StringVar(&OutputTemplate, `template`, OutputTemplate, `a go template to use to render the output`)
+	aliasFlag(fs, `template`, `t`)
+	fs.Var(&OutputTemplateFiles, `template-file`, `read the template from this file instead of the command line, or templates it uses from more files or directories`)
+	aliasFlag(fs, `template-file`, `T`)
+	fs.StringVar(&PatchFile, `patch`, PatchFile, `a JSON Patch or JSON Merge Patch to apply to the input before searching`)
+	aliasFlag(fs, `patch`, `p`)
+	fs.BoolVar(&WithLocation, `with-location`, WithLocation, `prefix each result with the file, line and column it came from`)
+	aliasFlag(fs, `with-location`, `L`)
+	fs.BoolVar(&PrintPaths, `paths`, PrintPaths, `print the path of each result instead of rendering it`)
+	aliasFlag(fs, `paths`, `P`)
+	fs.BoolVar(&Strict, `strict`, Strict, `fail if any part of the search path finds nothing`)
+	fs.BoolVar(&CheckExitStatus, `exit-status`, CheckExitStatus, `exit with status 1 if nothing is found or the last result is false or null`)
+	aliasFlag(fs, `exit-status`, `e`)
+	fs.BoolVar(&Interactive, `interactive`, Interactive, `load the input file once and run search paths typed at a prompt`)
+	aliasFlag(fs, `interactive`, `I`)
+	fs.BoolVar(&RawOutput, `raw`, RawOutput, `print strings as they are and anything else as JSON, one result per line, without a template`)
+	aliasFlag(fs, `raw`, `r`)
+	fs.BoolVar(&JoinOutput, `join-output`, JoinOutput, `like --raw, but without a line break after each result`)
+	aliasFlag(fs, `join-output`, `j`)
+	fs.BoolVar(&NullOutput, `null-output`, NullOutput, `like --raw, but end each result with a NUL character, for xargs -0`)
+	aliasFlag(fs, `null-output`, `0`)
+	defineLayoutFlags(fs)
+	defineSandboxFlags(fs)
+	defineStyleFlags(fs)
+}
+
+// parseQueryOpts parses the options of the query command. The first two
+// positional arguments can stand in for --in and --out.
+func parseQueryOpts(args []string) (*flag.FlagSet, error) {
+	flags := newFlagSet(`query`)
+	defineFlags(flags)
+	if err := parseFlags(flags, args, Usage); err != nil {
+		return nil, err
+	}
+
+	InputFormat = LookupFormat(inputFormatName)
+	if infile := flags.Arg(0); InputFile == `-` && infile != `` {
 		InputFile = infile
 	}
-	if outfile := flag.Arg(1); OutputFile == `-` && outfile != `` {
+	if outfile := flags.Arg(1); OutputFile == `-` && outfile != `` {
 		OutputFile = outfile
 	}
+	return flags, nil
+}
+
+// executeWithLocations renders each result separately, prefixed with where
+// it came from as file:line:col so editors can jump to it. values are the
+// results as made by ResultFuncs. The header and footer are rendered once,
+// around all of them, without a location.
+func executeWithLocations(w io.Writer, tmplt *template.Template, doc *Document, results []Result, values []any) error {
+	data := TemplateLayout.Data(doc, values)
+	if err := executeAssociated(w, tmplt, headerTemplate, data); err != nil {
+		return err
+	}
+	for idx := range results {
+		rendered := new(bytes.Buffer)
+		if err := tmplt.Execute(rendered, values[idx:idx+1]); err != nil {
+			return err
+		}
+		text := strings.TrimSuffix(rendered.String(), "\n")
+		if _, err := fmt.Fprintf(w, "%s: %s\n", doc.Locate(results[idx].Location), text); err != nil {
+			return err
+		}
+	}
+	return executeAssociated(w, tmplt, footerTemplate, data)
+}
+
+// rawOutput reports whether results are printed without a template.
+func rawOutput() bool {
+	return RawOutput || JoinOutput || NullOutput
+}
+
+// outputSeparator is what ends each result in raw output, or each path
+// printed by --paths.
+func outputSeparator() string {
+	switch {
+	case NullOutput:
+		return "\x00"
+	case JoinOutput:
+		return ``
+	default:
+		return "\n"
+	}
+}
+
+// writeRaw prints each result without a template: strings as they are, and
+// anything else as compact JSON, so that scripts get bare values to work
+// with.
+func writeRaw(w io.Writer, doc *Document, results []Result, sep string) error {
+	for _, result := range results {
+		text, ok := result.Value.(string)
+		if !ok {
+			out, err := OutputStyle.JSON(result.Value, false)
+			if err != nil {
+				return err
+			}
+			text = string(out)
+		}
+		if WithLocation {
+			text = fmt.Sprintf(`%s: %s`, doc.Locate(result.Location), text)
+		}
+		if _, err := fmt.Fprintf(w, `%s%s`, text, sep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringList is an option that can be given more than once.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, `, `)
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// flagWasSet reports whether any of the named flags were given on the
+// command line.
+func flagWasSet(flags *flag.FlagSet, names ...string) bool {
+	var set bool
+	flags.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = true
+			}
+		}
+	})
+	return set
+}
+
+// newFlagSet makes the flag set for a command, with the options that every
+// command has. Use parseFlags to parse it.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	fs.StringVar(&ErrorFormat, `error-format`, ErrorFormat, `how to report errors; text|json`)
+	return fs
+}
+
+// parseFlags parses a command's options. For -h, it prints usage, the
+// command's help, and returns ExitStatus(0) to end the program. Options
+// that can't be understood are a UsageError.
+func parseFlags(fs *flag.FlagSet, args []string, usage string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, strings.ReplaceAll(usage, `%s`, os.Args[0]))
+		return ExitStatus(0)
+	}
+	if err != nil {
+		return &UsageError{Err: fmt.Errorf(`%w; use -h to see the options`, err)}
+	}
+	switch ErrorFormat {
+	case `text`, `json`:
+		return nil
+	default:
+		return &UsageError{Err: fmt.Errorf(`unknown error format %q; use text or json`, ErrorFormat)}
+	}
+}
+
+// aliasFlag lets an option also be given by other names, like a short
+// form, which share its value and usage.
+func aliasFlag(fs *flag.FlagSet, name string, aliases ...string) {
+	f := fs.Lookup(name)
+	for _, alias := range aliases {
+		fs.Var(f.Value, alias, f.Usage)
+	}
 }
 
 func main() {
-	getOpts()
-	data, err := Parse(InputFile, InputFormat)
+	command, args := runQuery, os.Args[1:]
+	if len(args) > 0 {
+		if named, ok := Commands[args[0]]; ok {
+			command, args = named, args[1:]
+		}
+	}
+	err := command(args)
+	var status ExitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
 	if err != nil {
-		log.Print(err)
-		os.Exit(-1)
+		fail(err, ExitFailure)
 	}
-	filtered, err := Evaluate(data, SearchPath)
+}
+
+// runQuery searches a file and renders the results. It is what stool does
+// when the first argument isn't the name of another command.
+func runQuery(args []string) error {
+	flags, err := parseQueryOpts(args)
 	if err != nil {
-		log.Print(err)
-		os.Exit(-1)
+		return err
+	}
+	if err := OutputStyle.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+	if err := TemplateSandbox.Check(); err != nil {
+		return &UsageError{Err: err}
+	}
+	if TemplateLayout.Mode, err = LookupTemplateMode(templateModeName); err != nil {
+		return &UsageError{Err: err}
+	}
+	if PatchFile != `` && WithLocation {
+		return &UsageError{Err: fmt.Errorf(`--with-location reports where results are in the input, so it can't be used with --patch, which changes the input`)}
 	}
-	tmplt, err := GetTemplate(OutputTemplate, OutputTemplateFile)
+	if TemplateLayout.Mode == TemplateAll && WithLocation && !PrintPaths && !rawOutput() {
+		return &UsageError{Err: fmt.Errorf(`--with-location renders each result separately, so it can't be used with --template-mode all`)}
+	}
+	if WithLocation && flagWasSet(flags, `separator`) && !PrintPaths && !rawOutput() {
+		return &UsageError{Err: fmt.Errorf(`--with-location puts each result on its own line, so it can't be used with --separator`)}
+	}
+	if rawOutput() && flagWasSet(flags, `template`, `t`, `template-file`, `T`, `separator`, `header`, `footer`) {
+		return &UsageError{Err: fmt.Errorf(`--raw, --join-output and --null-output print results without a template, so they can't be used with one`)}
+	}
+	if Interactive && InputFile == `-` {
+		return &UsageError{Err: fmt.Errorf(`--interactive needs a file to read, because STDIN is used for the prompt`)}
+	}
+	doc, err := ParseDocument(InputFile, InputFormat)
 	if err != nil {
-		log.Print(err)
-		os.Exit(-1)
+		return withStatus(err, ExitParse)
 	}
 	buff := new(bytes.Buffer)
-	if err := tmplt.Execute(buff, filtered); err != nil {
-		log.Print(err)
-		os.Exit(-1)
+	if PatchFile != `` {
+		doc.Data, err = ApplyPatchFile(doc.Data, PatchFile)
+		if err != nil {
+			return err
+		}
+		doc.Patched = true
+	}
+	if Interactive {
+		return withStatus(RunREPL(doc, os.Stdin, os.Stdout), ExitIO)
+	}
+	var status ExitStatus
+	if PatchFile != `` && !flagWasSet(flags, `search`, `s`, `template`, `t`, `template-file`, `T`) {
+		out, err := Marshal(doc.Data, doc.Format)
+		if err != nil {
+			return err
+		}
+		buff.Write(out)
+	} else {
+		evaluate := EvaluateResults
+		if Strict {
+			evaluate = EvaluateStrict
+		}
+		results, err := evaluate(doc.Data, SearchPath)
+		if err != nil {
+			return withStatus(err, ExitQuery)
+		}
+		if CheckExitStatus && (len(results) == 0 || isFalsy(results[len(results)-1].Value)) {
+			status = ExitNoResults
+		}
+		tmplt, err := GetTemplate(OutputTemplate, OutputTemplateFiles...)
+		if err != nil {
+			return withStatus(err, ExitTemplate)
+		}
+		switch {
+		case PrintPaths:
+			for _, result := range results {
+				if WithLocation {
+					fmt.Fprintf(buff, `%s: `, doc.Locate(result.Location))
+				}
+				fmt.Fprintf(buff, `%s%s`, result.Location, outputSeparator())
+			}
+		case rawOutput():
+			if err := writeRaw(buff, doc, results, outputSeparator()); err != nil {
+				return err
+			}
+		case WithLocation:
+			funcs, values := ResultFuncs(doc, results)
+			err = TemplateSandbox.Run(buff, tmplt, funcs, func(w io.Writer, tmplt *template.Template) error {
+				return executeWithLocations(w, tmplt, doc, results, values)
+			})
+		default:
+			funcs, values := ResultFuncs(doc, results)
+			err = TemplateSandbox.Run(buff, tmplt, funcs, func(w io.Writer, tmplt *template.Template) error {
+				return ExecuteTemplate(w, tmplt, TemplateLayout.Data(doc, values))
+			})
+		}
+		if err != nil {
+			return &TemplateError{Name: tmplt.Name(), Err: err}
+		}
 	}
 	if OutputFile == `-` {
-		fmt.Printf(`%s`, string(buff.Bytes()))
-		return
+		if _, err := os.Stdout.Write(buff.Bytes()); err != nil {
+			return withStatus(err, ExitIO)
+		}
+	} else if err := ioutil.WriteFile(OutputFile, buff.Bytes(), 0644); err != nil {
+		return withStatus(err, ExitIO)
 	}
-	if err := ioutil.WriteFile(OutputFile, buff.Bytes(), 0644); err != nil {
-		log.Print(err)
-		os.Exit(-1)
+	if status != 0 {
+		return status
 	}
+	return nil
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ExitStatusTestCase struct {
+	Err      error
+	Fallback ExitStatus
+	Expected ExitStatus
+}
+
+func (estc ExitStatusTestCase) Test(t *testing.T) {
+	t.Helper()
+	assert.Equal(t, estc.Expected, exitStatusOf(estc.Err, estc.Fallback), fmt.Sprint(estc.Err))
+}
+
+func parseError(filename string) error {
+	_, err := ParseDocument(filename, FormatUnknown)
+	return err
+}
+
+func commandError(run func([]string) error, args ...string) error {
+	return run(args)
+}
+
+var ExitStatusTestCases = []ExitStatusTestCase{
+	{
+		Err:      fmt.Errorf(`something went wrong`),
+		Fallback: ExitQuery,
+		Expected: ExitQuery,
+	},
+	{
+		Err:      fmt.Errorf(`wrapped: %w`, ExitStatus(7)),
+		Fallback: ExitFailure,
+		Expected: ExitStatus(7),
+	},
+	{
+		Err:      parseError(`test_data/does_not_exist.yaml`),
+		Fallback: ExitParse,
+		Expected: ExitIO,
+	},
+	{
+		Err:      &NoResultsError{Path: `animal`, Chunk: `animal`},
+		Fallback: ExitQuery,
+		Expected: ExitNoResults,
+	},
+	{
+		Err:      commandError(runDiff, `--bogus`),
+		Fallback: ExitFailure,
+		Expected: ExitUsage,
+	},
+	{
+		Err:      commandError(runDiff, `test_data/test.yaml`),
+		Fallback: ExitFailure,
+		Expected: ExitUsage,
+	},
+	{
+		Err:      commandError(runMerge),
+		Fallback: ExitFailure,
+		Expected: ExitUsage,
+	},
+}
+
+func TestExitStatus(t *testing.T) {
+	for _, tc := range ExitStatusTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	defer func(infile, search string) {
+		InputFile, SearchPath = infile, search
+	}(InputFile, SearchPath)
+	err := runQuery([]string{`test_data/malformed.yaml`})
+	assert.Equal(t, ExitParse, exitStatusOf(err, ExitFailure))
+	err = runQuery([]string{`--in`, `test_data/test.yaml`, `--search`, `a.lenght()`})
+	assert.Equal(t, ExitQuery, exitStatusOf(err, ExitFailure))
+	err = runQuery([]string{`--bogus`})
+	var ue *UsageError
+	assert.ErrorAs(t, err, &ue)
+	assert.Equal(t, ExitUsage, exitStatusOf(err, ExitFailure))
+	assert.Equal(t, ExitIO, exitStatusOf(withStatus(errors.New(`broken pipe`), ExitIO), ExitFailure))
+	assert.NoError(t, withStatus(nil, ExitIO))
+}
+
+func TestParseFlags(t *testing.T) {
+	defer func(format string) { ErrorFormat = format }(ErrorFormat)
+	var (
+		long string
+		help = newFlagSet(`test`)
+	)
+	help.StringVar(&long, `long`, long, `an option`)
+	aliasFlag(help, `long`, `l`)
+	assert.Equal(t, ExitStatus(0), parseFlags(help, []string{`-h`}, ``))
+
+	fs := newFlagSet(`test`)
+	fs.StringVar(&long, `long`, long, `an option`)
+	aliasFlag(fs, `long`, `l`)
+	assert.NoError(t, parseFlags(fs, []string{`-l`, `x`, `--error-format`, `json`}, ``))
+	assert.Equal(t, `x`, long)
+	assert.Equal(t, `json`, ErrorFormat)
+	assert.Equal(t, `an option`, fs.Lookup(`l`).Usage)
+
+	var ue *UsageError
+	assert.ErrorAs(t, parseFlags(newFlagSet(`test`), []string{`--long`, `y`}, ``), &ue)
+	assert.ErrorAs(t, parseFlags(newFlagSet(`test`), []string{`--error-format`, `yaml`}, ``), &ue)
+}
+
+func TestIsFalsy(t *testing.T) {
+	assert.True(t, isFalsy(nil))
+	assert.True(t, isFalsy(false))
+	assert.False(t, isFalsy(true))
+	assert.False(t, isFalsy(0))
+	assert.False(t, isFalsy(``))
+}
+
+type RawTestCase struct {
+	Path      string
+	Separator string
+	Expected  string
+}
+
+func (rtc RawTestCase) Test(t *testing.T) {
+	t.Helper()
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(doc.Data, rtc.Path)
+	assert.NoError(t, err, rtc.Path)
+	buff := new(strings.Builder)
+	assert.NoError(t, writeRaw(buff, doc, results, rtc.Separator), rtc.Path)
+	assert.Equal(t, rtc.Expected, buff.String(), rtc.Path)
+}
+
+var RawTestCases = []RawTestCase{
+	{
+		Path:      `animals.vertebrates.mammals[*]`,
+		Separator: "\n",
+		Expected:  "horse\nshrew\ncat\n",
+	},
+	{
+		Path:      `animals.vertebrates.mammals[*]`,
+		Separator: ``,
+		Expected:  `horseshrewcat`,
+	},
+	{
+		Path:      `minerals.keys()[*]`,
+		Separator: "\x00",
+		Expected:  "igneous\x00metamorphic\x00sedimentary\x00",
+	},
+	{
+		Path:      `animals.invertebrates`,
+		Separator: "\n",
+		Expected:  "{\"mollusks\":[\"clam\"],\"insects\":[\"fly\",\"ant\"]}\n",
+	},
+	{
+		Path:      `minerals.igneous.length()`,
+		Separator: "\n",
+		Expected:  "3\n",
+	},
+	{
+		Path:      `nothing`,
+		Separator: "\n",
+		Expected:  ``,
+	},
+}
+
+func TestWriteRaw(t *testing.T) {
+	for _, tc := range RawTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestExecuteWithLocations(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(doc.Data, `minerals.igneous[*]`)
+	assert.NoError(t, err)
+
+	defer func(layout Layout) { TemplateLayout = layout }(TemplateLayout)
+	TemplateLayout = Layout{Escapes: true, Header: `{{ len . }} found\n`, Footer: `done\n`}
+	tmplt, err := TemplateLayout.Parse(`cmdline`, `{{ . }}`)
+	assert.NoError(t, err)
+	funcs, values := ResultFuncs(doc, results)
+	buff := new(strings.Builder)
+	assert.NoError(t, executeWithLocations(buff, tmplt.Funcs(funcs), doc, results, values))
+	assert.Equal(t, "3 found\ntest_data/test.yaml:42:7: obsidian\ntest_data/test.yaml:43:7: granite\ntest_data/test.yaml:44:7: basalt\ndone\n", buff.String())
+
+	defer func(withLocation bool) { WithLocation = withLocation }(WithLocation)
+	var ue *UsageError
+	assert.ErrorAs(t, runQuery([]string{`-L`, `--separator`, `, `, `test_data/test.yaml`}), &ue)
+}
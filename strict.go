@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxAvailableKeys limits how many keys a NoResultsError lists, so that a
+// typo in a large map doesn't fill the screen.
+const maxAvailableKeys = 20
+
+// NoResultsError is returned by a strict search when part of the path finds
+// nothing at all.
+type NoResultsError struct {
+	Path       string   // the whole search path
+	Chunk      string   // the part of the path that found nothing
+	Offset     int      // where Chunk starts in Path, in runes
+	Available  []string // the keys of the maps the chunk was applied to
+	Suggestion string   // the available key closest to Chunk, if any is close
+}
+
+func (e *NoResultsError) Error() string {
+	msg := fmt.Sprintf(`%q found nothing`, e.Chunk)
+	if prefix := strings.TrimSuffix(e.Path[:e.byteOffset()], `.`); prefix != `` {
+		msg += fmt.Sprintf(` in %q`, prefix)
+	}
+	if len(e.Available) != 0 {
+		keys := e.Available
+		more := ``
+		if len(keys) > maxAvailableKeys {
+			more = fmt.Sprintf(` and %d more`, len(keys)-maxAvailableKeys)
+			keys = keys[:maxAvailableKeys]
+		}
+		msg += fmt.Sprintf(`; available keys are %s%s`, strings.Join(keys, `, `), more)
+	}
+	if e.Suggestion != `` {
+		msg += fmt.Sprintf(`; did you mean %q?`, e.Suggestion)
+	}
+	return msg
+}
+
+func (e *NoResultsError) byteOffset() int {
+	offset := 0
+	for idx := 0; idx < e.Offset && offset < len(e.Path); idx++ {
+		_, size := utf8.DecodeRuneInString(e.Path[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// newNoResultsError describes the chunk of path between start and end, which
+// turned the results in before into nothing.
+func newNoResultsError(path []rune, start, end int, before []Result) *NoResultsError {
+	chunk := strings.TrimSpace(string(path[start:end]))
+	available := availableKeys(before)
+	return &NoResultsError{
+		Path:       string(path),
+		Chunk:      chunk,
+		Offset:     start,
+		Available:  available,
+		Suggestion: suggestKey(strings.Trim(chunk, `[]'"`), available),
+	}
+}
+
+// availableKeys lists every key of every map in results, sorted and without
+// duplicates.
+func availableKeys(results []Result) []string {
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if dict, ok := result.Value.(*Map); ok {
+			for _, key := range dict.Keys() {
+				seen[key] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggestKey finds the key that is most likely to be what was meant by
+// word. Keys that are too different to be a typo are not suggested.
+func suggestKey(word string, keys []string) string {
+	var (
+		best     string
+		bestDist = len([]rune(word))/3 + 2
+	)
+	for _, key := range keys {
+		if key == word {
+			continue
+		}
+		dist := editDistance(strings.ToLower(word), strings.ToLower(key))
+		if dist < bestDist {
+			best, bestDist = key, dist
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
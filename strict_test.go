@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type StrictTestCase struct {
+	Path               string
+	ExpectedChunk      string
+	ExpectedSuggestion string
+	ExpectedAvailable  []string
+	ExpectedResults    []any
+}
+
+func (stc StrictTestCase) Test(t *testing.T) {
+	t.Helper()
+	data, err := Parse(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err, stc.Path)
+
+	results, err := EvaluateStrict(data, stc.Path)
+	if stc.ExpectedChunk == `` {
+		assert.NoError(t, err, stc.Path)
+		assert.Equal(t, stc.ExpectedResults, Values(results), stc.Path)
+		return
+	}
+	var nre *NoResultsError
+	if !assert.ErrorAs(t, err, &nre, stc.Path) {
+		return
+	}
+	assert.Equal(t, stc.ExpectedChunk, nre.Chunk, stc.Path)
+	assert.Equal(t, stc.ExpectedSuggestion, nre.Suggestion, stc.Path)
+	assert.Equal(t, stc.ExpectedAvailable, nre.Available, stc.Path)
+}
+
+var StrictTestCases = []StrictTestCase{
+	{
+		Path:            `animals.vertebrates.reptiles[1]`,
+		ExpectedResults: []any{`snake`},
+	},
+	{
+		Path:               `animal.vertebrates`,
+		ExpectedChunk:      `animal`,
+		ExpectedSuggestion: `animals`,
+		ExpectedAvailable:  []string{`animals`, `meta`, `minerals`, `vegetables`},
+	},
+	{
+		Path:               `animals.vertebrates.Mamals`,
+		ExpectedChunk:      `Mamals`,
+		ExpectedSuggestion: `mammals`,
+		ExpectedAvailable:  []string{`mammals`, `reptiles`},
+	},
+	{
+		Path:              `animals.vertebrates.xyzzy`,
+		ExpectedChunk:     `xyzzy`,
+		ExpectedAvailable: []string{`mammals`, `reptiles`},
+	},
+	{
+		Path:               `minerals["igneos"]`,
+		ExpectedChunk:      `["igneos"]`,
+		ExpectedSuggestion: `igneous`,
+		ExpectedAvailable:  []string{`igneous`, `metamorphic`, `sedimentary`},
+	},
+	{
+		Path:              `animals.vertebrates.mammals[7]`,
+		ExpectedChunk:     `[7]`,
+		ExpectedAvailable: []string{},
+	},
+	{
+		Path:              `minerals[*][. == "diamond"]`,
+		ExpectedChunk:     `[. == "diamond"]`,
+		ExpectedAvailable: []string{},
+	},
+}
+
+func TestStrict(t *testing.T) {
+	for _, tc := range StrictTestCases {
+		tc.Test(t)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance(`animals`, `animals`))
+	assert.Equal(t, 1, editDistance(`animal`, `animals`))
+	assert.Equal(t, 2, editDistance(`mamals`, `mammal`))
+	assert.Equal(t, 3, editDistance(`kitten`, `sitting`))
+	assert.Equal(t, 5, editDistance(``, `hello`))
+}
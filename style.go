@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Style controls how values are written out as JSON and YAML, by the
+// path functions, the template functions and the commands that write
+// whole documents.
+type Style struct {
+	// Indent is the number of spaces for each level of nesting.
+	Indent int
+	// Tabs indents JSON with a tab for each level instead. YAML can't be
+	// indented with tabs, so it still uses Indent.
+	Tabs bool
+	// Flow writes YAML maps and lists inline, like {a: 1, b: [x, y]}.
+	Flow bool
+	// Literal writes strings that have line breaks in them as YAML literal
+	// blocks. Otherwise they are double quoted, with \n for each break.
+	Literal bool
+	// EscapeHTML writes <, > and & in JSON strings as \u003c and so on, so
+	// the JSON can be put inside HTML safely.
+	EscapeHTML bool
+}
+
+// DefaultStyle indents by four spaces and otherwise matches what the Go
+// JSON and YAML packages do by default.
+var DefaultStyle = Style{
+	Indent:     4,
+	Literal:    true,
+	EscapeHTML: true,
+}
+
+// OutputStyle is the Style set on the command line.
+var OutputStyle = DefaultStyle
+
+// defineStyleFlags registers the options that set OutputStyle.
+func defineStyleFlags(fs *flag.FlagSet) {
+	fs.IntVar(&OutputStyle.Indent, `indent`, OutputStyle.Indent, `the number of spaces to indent each level of JSON and YAML`)
+	fs.BoolVar(&OutputStyle.Tabs, `tabs`, OutputStyle.Tabs, `indent JSON with tabs`)
+	fs.BoolVar(&OutputStyle.Flow, `yaml-flow`, OutputStyle.Flow, `write YAML maps and lists inline`)
+	fs.BoolVar(&OutputStyle.Literal, `literal`, OutputStyle.Literal, `write multi-line strings as YAML literal blocks`)
+	fs.BoolVar(&OutputStyle.EscapeHTML, `escape-html`, OutputStyle.EscapeHTML, `escape <, > and & in JSON strings`)
+}
+
+// Check reports settings that can't be used.
+func (s Style) Check() error {
+	if s.Indent < 1 || s.Indent > 9 {
+		return fmt.Errorf(`the indent must be from 1 to 9, not %d`, s.Indent)
+	}
+	return nil
+}
+
+// JSON renders a value as JSON, on one line unless it is pretty.
+func (s Style) JSON(value any, pretty bool) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(s.EscapeHTML)
+	if pretty {
+		indent := strings.Repeat(` `, s.Indent)
+		if s.Tabs {
+			indent = "\t"
+		}
+		enc.SetIndent(``, indent)
+	}
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buff.Bytes(), []byte("\n")), nil
+}
+
+// YAML renders a value as a YAML document. YAML can't be indented by less
+// than two spaces, so a smaller Indent is an error.
+func (s Style) YAML(value any) ([]byte, error) {
+	if s.Indent < 2 {
+		return nil, fmt.Errorf(`YAML can't be indented by less than 2 spaces, not %d`, s.Indent)
+	}
+	var node yaml.Node
+	if err := node.Encode(yamlNumbers(value)); err != nil {
+		return nil, err
+	}
+	if s.Flow && (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) {
+		node.Style |= yaml.FlowStyle
+	}
+	if !s.Literal {
+		quoteMultiline(&node)
+	}
+	buff := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buff)
+	enc.SetIndent(s.Indent)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func quoteMultiline(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == `!!str` && strings.Contains(node.Value, "\n") {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		quoteMultiline(child)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type StyleTestCase struct {
+	Style        Style
+	Value        any
+	ExpectedJSON string
+	ExpectedYAML string
+}
+
+func (stc StyleTestCase) Test(t *testing.T) {
+	t.Helper()
+	assert.NoError(t, stc.Style.Check())
+	out, err := stc.Style.JSON(stc.Value, true)
+	assert.NoError(t, err)
+	assert.Equal(t, stc.ExpectedJSON, string(out), stc.Style)
+	out, err = stc.Style.YAML(stc.Value)
+	assert.NoError(t, err)
+	assert.Equal(t, stc.ExpectedYAML, string(out), stc.Style)
+}
+
+var styleTestValue = map[string]any{
+	`list`: []any{`a`, `<b>`},
+	`text`: "one\ntwo\n",
+}
+
+var StyleTestCases = []StyleTestCase{
+	{
+		Style:        DefaultStyle,
+		Value:        styleTestValue,
+		ExpectedJSON: "{\n    \"list\": [\n        \"a\",\n        \"\\u003cb\\u003e\"\n    ],\n    \"text\": \"one\\ntwo\\n\"\n}",
+		ExpectedYAML: "list:\n    - a\n    - <b>\ntext: |\n    one\n    two\n",
+	},
+	{
+		Style:        Style{Indent: 2, Tabs: true, Literal: true},
+		Value:        styleTestValue,
+		ExpectedJSON: "{\n\t\"list\": [\n\t\t\"a\",\n\t\t\"<b>\"\n\t],\n\t\"text\": \"one\\ntwo\\n\"\n}",
+		ExpectedYAML: "list:\n  - a\n  - <b>\ntext: |\n  one\n  two\n",
+	},
+	{
+		Style:        Style{Indent: 4, Flow: true},
+		Value:        styleTestValue,
+		ExpectedJSON: "{\n    \"list\": [\n        \"a\",\n        \"<b>\"\n    ],\n    \"text\": \"one\\ntwo\\n\"\n}",
+		ExpectedYAML: "{list: [a, <b>], text: \"one\\ntwo\\n\"}\n",
+	},
+	{
+		Style:        Style{Indent: 4},
+		Value:        []any{"x\ny"},
+		ExpectedJSON: "[\n    \"x\\ny\"\n]",
+		ExpectedYAML: "- \"x\\ny\"\n",
+	},
+}
+
+func TestStyle(t *testing.T) {
+	for _, tc := range StyleTestCases {
+		tc.Test(t)
+	}
+	out, err := DefaultStyle.JSON(styleTestValue, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"list":["a","\u003cb\u003e"],"text":"one\ntwo\n"}`, string(out))
+	assert.Error(t, Style{Indent: 0}.Check())
+	_, err = Style{Indent: 1}.YAML(styleTestValue)
+	assert.ErrorContains(t, err, `less than 2 spaces`)
+	out, err = Style{Indent: 1}.JSON([]any{1}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n 1\n]", string(out))
+}
@@ -1,48 +1,574 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/masterminds/sprig"
-	yaml "gopkg.in/yaml.v3"
+	"golang.org/x/exp/slices"
 )
 
+// resultCursor is a hidden function called at the start of each result
+// so that functions like pos know which result is being rendered.
+const resultCursor = `stoolNextResult`
+
+// The names of the templates that go around and between the results. They
+// are named after their options, so that errors say where to look.
+const (
+	headerTemplate    = `--header`
+	separatorTemplate = `--separator`
+	footerTemplate    = `--footer`
+)
+
+// TemplateMode determines whether a template is rendered for each result,
+// or once for all of them.
+type TemplateMode int
+
+const (
+	// TemplateEach renders the template for each result in turn.
+	TemplateEach TemplateMode = iota
+	// TemplateAll renders the template once, with a TemplateData holding
+	// all the results and where they came from.
+	TemplateAll
+)
+
+// LookupTemplateMode interprets a user-supplied template mode name.
+func LookupTemplateMode(name string) (TemplateMode, error) {
+	switch strings.ToLower(name) {
+	case `each`:
+		return TemplateEach, nil
+	case `all`:
+		return TemplateAll, nil
+	default:
+		return TemplateEach, fmt.Errorf(`unknown template mode %q; use each or all`, name)
+	}
+}
+
+// TemplateData is what the template is given in TemplateAll mode.
+type TemplateData struct {
+	// Results are the values the search path found.
+	Results []any `json:"results"`
+	// Count is the number of results.
+	Count int `json:"count"`
+	// Filename is the file that was searched, or <stdin>.
+	Filename string `json:"filename"`
+	// Format is the format the file was read as: json or yaml.
+	Format string `json:"format"`
+}
+
+// MarshalYAML writes the data with the same names as JSON uses, keeping
+// numbers in the results exact.
+func (td TemplateData) MarshalYAML() (any, error) {
+	m := NewMap()
+	m.Set(`results`, td.Results)
+	m.Set(`count`, td.Count)
+	m.Set(`filename`, td.Filename)
+	m.Set(`format`, td.Format)
+	return m, nil
+}
+
+// Layout controls how the text of a template is read, and what is rendered
+// around and between the results.
+type Layout struct {
+	// Mode determines whether the template is rendered for each result or
+	// once for all of them.
+	Mode TemplateMode
+	// Escapes turns \n, \t, \r and \\ into the characters they stand for,
+	// outside of {{ }} actions, so line breaks can be typed on the command
+	// line.
+	Escapes bool
+	// Newline ends each rendered result with a line break, or the whole
+	// output in TemplateAll mode.
+	Newline bool
+	// Separator is a template rendered between each result and the next.
+	// It is not used in TemplateAll mode.
+	Separator string
+	// Header and Footer are templates rendered once, before and after all
+	// the results. They are given the same data as the template: a list of
+	// the results, or a TemplateData in TemplateAll mode.
+	Header string
+	Footer string
+}
+
+// TemplateLayout is the Layout set on the command line.
+var TemplateLayout Layout
+
+// templateModeName is the --template-mode option, which is looked up once
+// the options are parsed.
+var templateModeName = `each`
+
+// defineLayoutFlags registers the options that set TemplateLayout.
+func defineLayoutFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&TemplateLayout.Escapes, `escapes`, TemplateLayout.Escapes, `turn \n, \t, \r and \\ in templates into the characters they stand for`)
+	aliasFlag(fs, `escapes`, `E`)
+	fs.StringVar(&templateModeName, `template-mode`, templateModeName, `render the template for each result, or once for all of them; each|all`)
+	fs.BoolVar(&TemplateLayout.Newline, `newline`, TemplateLayout.Newline, `end each rendered result with a line break`)
+	fs.StringVar(&TemplateLayout.Separator, `separator`, TemplateLayout.Separator, `a go template to render between results`)
+	fs.StringVar(&TemplateLayout.Header, `header`, TemplateLayout.Header, `a go template to render once before the results`)
+	fs.StringVar(&TemplateLayout.Footer, `footer`, TemplateLayout.Footer, `a go template to render once after the results`)
+}
+
+// Parse makes the template that renders the results with text, along with
+// the header, separator and footer.
+func (l Layout) Parse(name, text string) (*template.Template, error) {
+	body := l.expand(text)
+	if l.Newline && l.Mode != TemplateEach {
+		body += "\n"
+	}
+	t := template.New(name).Funcs(FuncMap())
+	if _, err := t.Parse(body); err != nil {
+		return nil, &TemplateError{Name: name, Err: err}
+	}
+	if l.Mode == TemplateEach {
+		if err := l.loop(t); err != nil {
+			return nil, &TemplateError{Name: name, Err: err}
+		}
+	}
+	separator := l.Separator
+	if l.Mode != TemplateEach {
+		separator = ``
+	}
+	parts := []struct{ name, text string }{
+		{headerTemplate, l.Header},
+		{separatorTemplate, separator},
+		{footerTemplate, l.Footer},
+	}
+	for _, part := range parts {
+		if part.text == `` {
+			continue
+		}
+		if _, err := t.New(part.name).Parse(l.expand(part.text)); err != nil {
+			return nil, &TemplateError{Name: part.name, Err: err}
+		}
+	}
+	return t, nil
+}
+
+// bodyMarker stands in for the text of the template in the loop that
+// renders it for each result.
+const bodyMarker = `stoolBody`
+
+// loop puts the body of a template inside a loop over the results. The
+// loop is parsed on its own and the body's nodes are moved into it, rather
+// than putting the text inside the loop, because define blocks can't be
+// nested inside a range.
+func (l Layout) loop(t *template.Template) error {
+	text := `{{ range $stoolIndex, $stoolResult := . }}{{ ` + resultCursor + ` }}{{ ` + bodyMarker + ` }}`
+	if l.Separator != `` {
+		// The separator goes before the newline, so that each line but the
+		// last ends with it, like a list written one item per line.
+		text += `{{ if lt (add1 $stoolIndex) (len $) }}{{ template "` + separatorTemplate + `" $ }}{{ end }}`
+	}
+	if l.Newline {
+		text += "\n"
+	}
+	text += `{{ end }}`
+	loop, err := template.New(t.Name()).Funcs(FuncMap()).Funcs(template.FuncMap{bodyMarker: func() string { return `` }}).Parse(text)
+	if err != nil {
+		return err
+	}
+	var body []parse.Node
+	if t.Tree != nil {
+		body = t.Tree.Root.Nodes
+	}
+	list := loop.Tree.Root.Nodes[0].(*parse.RangeNode).List
+	nodes := make([]parse.Node, 0, len(list.Nodes)+len(body))
+	for _, node := range list.Nodes {
+		if action, ok := node.(*parse.ActionNode); ok && action.String() == `{{`+bodyMarker+`}}` {
+			nodes = append(nodes, body...)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	list.Nodes = nodes
+	_, err = t.AddParseTree(t.Name(), loop.Tree)
+	return err
+}
+
+// expand interprets escapes in the text of a template, if they are turned
+// on. Actions are left alone, because Go already interprets the escapes in
+// their strings.
+func (l Layout) expand(text string) string {
+	if !l.Escapes {
+		return text
+	}
+	var (
+		out      strings.Builder
+		inAction bool
+	)
+	for idx := 0; idx < len(text); idx++ {
+		switch {
+		case !inAction && strings.HasPrefix(text[idx:], `{{`):
+			inAction = true
+		case inAction && strings.HasPrefix(text[idx:], `}}`):
+			inAction = false
+		case !inAction && text[idx] == '\\' && idx+1 < len(text):
+			if char, ok := templateEscapes[text[idx+1]]; ok {
+				out.WriteByte(char)
+				idx++
+				continue
+			}
+		}
+		out.WriteByte(text[idx])
+	}
+	return out.String()
+}
+
+var templateEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'\\': '\\',
+}
+
 func FuncMap() template.FuncMap {
 	fm := sprig.TxtFuncMap()
-	fm[`yaml`] = func(v any) (string, error) {
-		b, e := yaml.Marshal(v)
-		return string(b), e
+	for name, fn := range valueFuncs(new(templateValues)) {
+		fm[name] = fn
 	}
-	fm[`yml`] = fm[`yaml`]
-	fm[`json`] = func(v any) (string, error) {
-		b, e := json.Marshal(v)
-		return string(b), e
+	fm[`toToml`] = templateToTOML
+	fm[`pos`] = func() (SourcePosition, error) {
+		return SourcePosition{}, fmt.Errorf(`pos is only available when rendering search results`)
 	}
-	fm[`js`] = fm[`json`]
-	fm[`jsonpretty`] = func(v any) (string, error) {
-		b, e := json.MarshalIndent(v, ``, `    `)
-		return string(b), e
+	fm[`path`] = func() (string, error) {
+		return ``, fmt.Errorf(`path is only available when rendering search results`)
+	}
+	fm[resultCursor] = func() string {
+		return ``
 	}
+	return fm
+}
+
+// valueFuncs are the template functions that make values or write them
+// out. They share values, so that maps are written with their keys in the
+// order they had in the document, as they are with --raw.
+func valueFuncs(values *templateValues) template.FuncMap {
+	fm := template.FuncMap{
+		`yaml`: func(v any) (string, error) {
+			b, e := OutputStyle.YAML(values.Normalize(v))
+			return string(b), e
+		},
+		`json`: func(v any) (string, error) {
+			b, e := OutputStyle.JSON(values.Normalize(v), false)
+			return string(b), e
+		},
+		`jsonpretty`: func(v any) (string, error) {
+			b, e := OutputStyle.JSON(values.Normalize(v), true)
+			return string(b), e
+		},
+		`query`:    values.query,
+		`fromYaml`: values.fromYAML,
+		`fromJson`: values.fromJSON,
+		`toCsv`:    values.toCSV,
+	}
+	fm[`yml`] = fm[`yaml`]
+	fm[`js`] = fm[`json`]
 	fm[`jspretty`] = fm[`jsonpretty`]
+	sprigFuncs := sprig.TxtFuncMap()
+	for _, name := range []string{`toJson`, `toPrettyJson`} {
+		toJSON := sprigFuncs[name].(func(any) string)
+		fm[name] = func(v any) string {
+			return toJSON(values.Normalize(v))
+		}
+	}
 	return fm
 }
 
-func GetTemplate(ttext, tfile string) (*template.Template, error) {
-	t := template.New(`cmdline`)
-	t = t.Funcs(FuncMap())
-	if tfile != `` {
-		tfilebytes, err := os.ReadFile(tfile)
+// ResultFuncs are the functions for one rendering of the results: the ones
+// that describe the result being rendered, like pos and path, and the ones
+// that make and write values. It also returns the results as the values
+// the template is given. Bind the functions before each Execute.
+func ResultFuncs(doc *Document, results []Result) (template.FuncMap, []any) {
+	values := new(templateValues)
+	cursor := -1
+	current := func() (Result, error) {
+		if cursor < 0 || cursor >= len(results) {
+			return Result{}, fmt.Errorf(`not rendering a search result`)
+		}
+		return results[cursor], nil
+	}
+	fm := valueFuncs(values)
+	fm[resultCursor] = func() string {
+		cursor++
+		return ``
+	}
+	fm[`pos`] = func() (SourcePosition, error) {
+		result, err := current()
+		if err != nil {
+			return SourcePosition{}, err
+		}
+		if doc.Patched {
+			return SourcePosition{}, fmt.Errorf(`pos can't be used with --patch, because the patched document isn't in the input file`)
+		}
+		return doc.Locate(result.Location), nil
+	}
+	fm[`path`] = func() (string, error) {
+		result, err := current()
+		if err != nil {
+			return ``, err
+		}
+		return result.Location.String(), nil
+	}
+	return fm, values.Value(Values(results)).([]any)
+}
+
+// TemplatePathVariable is the environment variable that lists directories
+// to look for templates in, separated like PATH.
+const TemplatePathVariable = `STOOL_TEMPLATE_PATH`
+
+// GetTemplate makes the template that renders the results. It is ttext,
+// the --template option, unless one of tfiles, the --template-file
+// options, names a file. The other files, and every file in a directory,
+// are parsed too, so that the templates they define can be used. Any
+// template that is used but not defined is looked for in
+// STOOL_TEMPLATE_PATH. With --safe-template, the template is restricted by
+// TemplateSandbox.
+func GetTemplate(ttext string, tfiles ...string) (*template.Template, error) {
+	var (
+		name     = `cmdline`
+		fromFile bool
+		library  []string
+	)
+	for _, tfile := range tfiles {
+		filenames, direct, err := templateFiles(tfile)
 		if err != nil {
-			return nil, fmt.Errorf(`could not read template file %q: %w`, tfile, err)
+			return nil, err
 		}
-		ttext = string(tfilebytes)
+		if direct && !fromFile {
+			tfilebytes, err := os.ReadFile(filenames[0])
+			if err != nil {
+				return nil, fmt.Errorf(`could not read template file %q: %w`, filenames[0], err)
+			}
+			ttext, name, fromFile = string(tfilebytes), filenames[0], true
+			continue
+		}
+		library = append(library, filenames...)
 	}
-	t, err := t.Parse(`{{ range . }}` + ttext + `{{ end }}`)
+	t, err := TemplateLayout.Parse(name, ttext)
 	if err != nil {
-		return nil, fmt.Errorf(`unable to parse template: %w`, err)
+		return nil, err
+	}
+	for _, filename := range library {
+		if err := parseTemplateFile(t, filepath.Base(filename), filename); err != nil {
+			return nil, err
+		}
+	}
+	if err := includeTemplates(t); err != nil {
+		return nil, err
+	}
+	if err := TemplateSandbox.Restrict(t); err != nil {
+		return nil, err
 	}
 	return t, nil
 }
+
+// templateFiles finds the files a --template-file option refers to. It can
+// be a file, a directory, whose files are all used, or the name of a
+// template in STOOL_TEMPLATE_PATH. direct is false for a directory.
+func templateFiles(tfile string) (filenames []string, direct bool, err error) {
+	info, err := os.Stat(tfile)
+	if errors.Is(err, fs.ErrNotExist) {
+		if filename, ok := findTemplate(tfile); ok {
+			return []string{filename}, true, nil
+		}
+		return nil, false, fmt.Errorf(`could not find template file %q here or in %s: %w`, tfile, TemplatePathVariable, err)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf(`could not read template file %q: %w`, tfile, err)
+	}
+	if !info.IsDir() {
+		return []string{tfile}, true, nil
+	}
+	entries, err := os.ReadDir(tfile)
+	if err != nil {
+		return nil, false, fmt.Errorf(`could not read template directory %q: %w`, tfile, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), `.`) {
+			filenames = append(filenames, filepath.Join(tfile, entry.Name()))
+		}
+	}
+	return filenames, false, nil
+}
+
+// findTemplate looks for a template in the directories in
+// STOOL_TEMPLATE_PATH, by its filename with or without ".tmpl". Names
+// that would leave those directories, like "../secrets", aren't looked up.
+func findTemplate(name string) (string, bool) {
+	clean := filepath.Clean(name)
+	if name == `` || filepath.IsAbs(name) || clean == `..` || strings.HasPrefix(clean, `..`+string(filepath.Separator)) {
+		return ``, false
+	}
+	for _, dir := range filepath.SplitList(os.Getenv(TemplatePathVariable)) {
+		if dir == `` {
+			continue
+		}
+		for _, filename := range []string{name, name + `.tmpl`} {
+			filename = filepath.Join(dir, filename)
+			if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+				return filename, true
+			}
+		}
+	}
+	return ``, false
+}
+
+// parseTemplateFile adds a file to a set of templates under the given name,
+// along with the templates it defines.
+func parseTemplateFile(t *template.Template, name, filename string) error {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(`could not read template file %q: %w`, filename, err)
+	}
+	if _, err := t.New(name).Parse(string(text)); err != nil {
+		return &TemplateError{Name: filename, Err: err}
+	}
+	return nil
+}
+
+// includeTemplates loads the templates that are used but not defined from
+// STOOL_TEMPLATE_PATH, and then any that those use in turn. Templates that
+// can't be found are left for Execute to report.
+func includeTemplates(t *template.Template) error {
+	tried := make(map[string]bool)
+	for {
+		var loaded bool
+		for _, name := range missingTemplates(t) {
+			if tried[name] {
+				continue
+			}
+			tried[name] = true
+			filename, ok := findTemplate(name)
+			if !ok {
+				continue
+			}
+			if err := parseTemplateFile(t, name, filename); err != nil {
+				return err
+			}
+			loaded = true
+		}
+		if !loaded {
+			return nil
+		}
+	}
+}
+
+// missingTemplates lists the templates used by {{ template }} actions that
+// haven't been defined.
+func missingTemplates(t *template.Template) []string {
+	var names []string
+	for _, defined := range t.Templates() {
+		if defined.Tree == nil {
+			continue
+		}
+		for _, name := range templateReferences(defined.Tree.Root) {
+			if t.Lookup(name) == nil && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func templateReferences(node parse.Node) []string {
+	var names []string
+	walkTemplate(node, func(n parse.Node) {
+		if tn, ok := n.(*parse.TemplateNode); ok {
+			names = append(names, tn.Name)
+		}
+	})
+	return names
+}
+
+// walkTemplate calls visit for node and every node inside it, including
+// the commands and arguments of actions.
+func walkTemplate(node parse.Node, visit func(parse.Node)) {
+	if list, ok := node.(*parse.ListNode); ok && list == nil {
+		return
+	}
+	if pipe, ok := node.(*parse.PipeNode); ok && pipe == nil {
+		return
+	}
+	visit(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			walkTemplate(child, visit)
+		}
+	case *parse.ActionNode:
+		walkTemplate(n.Pipe, visit)
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkTemplate(cmd, visit)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkTemplate(arg, visit)
+		}
+	case *parse.ChainNode:
+		walkTemplate(n.Node, visit)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, visit)
+	case *parse.TemplateNode:
+		walkTemplate(n.Pipe, visit)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, visit func(parse.Node)) {
+	walkTemplate(n.Pipe, visit)
+	walkTemplate(n.List, visit)
+	walkTemplate(n.ElseList, visit)
+}
+
+// Data is what a template made by Parse should be given to render the
+// results found in doc, given as the values made by ResultFuncs.
+func (l Layout) Data(doc *Document, values []any) any {
+	if l.Mode == TemplateEach {
+		return values
+	}
+	filename := doc.Filename
+	if filename == `-` {
+		filename = `<stdin>`
+	}
+	return TemplateData{
+		Results:  values,
+		Count:    len(values),
+		Filename: filename,
+		Format:   formatName(doc.Format),
+	}
+}
+
+// ExecuteTemplate renders the results, with the header and footer, if
+// there are any, around them.
+func ExecuteTemplate(w io.Writer, t *template.Template, data any) error {
+	if err := executeAssociated(w, t, headerTemplate, data); err != nil {
+		return err
+	}
+	if err := t.Execute(w, data); err != nil {
+		return err
+	}
+	return executeAssociated(w, t, footerTemplate, data)
+}
+
+// executeAssociated renders the template called name that was parsed
+// along with t, like the header, if there is one.
+func executeAssociated(w io.Writer, t *template.Template, name string, data any) error {
+	if associated := t.Lookup(name); associated != nil {
+		return associated.Execute(w, data)
+	}
+	return nil
+}
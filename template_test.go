@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,7 +18,7 @@ type TemplateTestCase struct {
 func (ttc TemplateTestCase) Test(t *testing.T) {
 	t.Helper()
 
-	tmplt, err := GetTemplate(ttc.Template, ``)
+	tmplt, err := GetTemplate(ttc.Template)
 	assert.NoError(t, err)
 
 	buff := new(bytes.Buffer)
@@ -92,3 +95,251 @@ func TestTemplate(t *testing.T) {
 		tc.Test(t)
 	}
 }
+
+func TestTemplateResultPath(t *testing.T) {
+	data, err := Parse(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(data, `animals.vertebrates[*][. == "cat"]`)
+	assert.NoError(t, err)
+
+	tmplt, err := GetTemplate(`{{ path }}: {{ . }}`)
+	assert.NoError(t, err)
+	funcs, values := ResultFuncs(&Document{Data: data}, results)
+	buff := new(bytes.Buffer)
+	assert.NoError(t, tmplt.Funcs(funcs).Execute(buff, values))
+	assert.Equal(t, `animals.vertebrates.mammals[2]: cat`, buff.String())
+}
+
+func TestTemplateNumbers(t *testing.T) {
+	data, err := decodeJSON([]byte(`{"price": 1.5, "count": 3, "ratio": 0.1, "exact": 1.50, "big": 12345678901234567890, "far": 1e400}`))
+	assert.NoError(t, err)
+	results, err := EvaluateResults(data, `.`)
+	assert.NoError(t, err)
+
+	tmplt, err := GetTemplate(`{{ if gt .price 1.0 }}big{{ end }} {{ if lt .count 5 }}few{{ end }} {{ if eq .ratio 0.1 }}tenth{{ end }} {{ .price }} {{ .exact }} {{ .big }} {{ .far }}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	funcs, values := ResultFuncs(&Document{Data: data}, results)
+	buff := new(bytes.Buffer)
+	assert.NoError(t, ExecuteTemplate(buff, tmplt.Funcs(funcs), TemplateLayout.Data(&Document{Data: data}, values)))
+	assert.Equal(t, `big few tenth 1.5 1.50 12345678901234567890 1e400`, buff.String())
+
+	data, err = decodeJSON([]byte(`{"f": 1.10, "e": 1e2, "z": -0.0, "p": 1.5, "n": -7}`))
+	assert.NoError(t, err)
+	results, err = EvaluateResults(data, `.`)
+	assert.NoError(t, err)
+	tmplt, err = GetTemplate(OutputTemplate)
+	if !assert.NoError(t, err) {
+		return
+	}
+	funcs, values = ResultFuncs(&Document{Data: data}, results)
+	buff.Reset()
+	assert.NoError(t, ExecuteTemplate(buff, tmplt.Funcs(funcs), TemplateLayout.Data(&Document{Data: data}, values)))
+	assert.Equal(t, "f: 1.10\ne: 1e2\nz: -0.0\np: 1.5\nn: -7\n", buff.String())
+}
+
+func TestTemplateOrder(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(doc.Data, `.`)
+	assert.NoError(t, err)
+	converted := map[Format]string{}
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		out, err := ConvertDocument(doc, format)
+		assert.NoError(t, err)
+		converted[format] = strings.TrimSpace(string(out))
+	}
+	compact, err := DefaultStyle.JSON(doc.Data, false)
+	assert.NoError(t, err)
+
+	for ttext, expected := range map[string]string{
+		OutputTemplate:       converted[FormatYAML],
+		`{{ jsonpretty . }}`: converted[FormatJSON],
+		`{{ json . }}`:       string(compact),
+		`{{ toJson . }}`:     string(compact),
+		`{{ (fromJson (toJson .)).animals | json }}`: `{"vertebrates":{"mammals":["horse","shrew","cat"],"reptiles":["lizard","snake","newt"]},"invertebrates":{"mollusks":["clam"],"insects":["fly","ant"]}}`,
+	} {
+		tmplt, err := GetTemplate(ttext)
+		if !assert.NoError(t, err, ttext) {
+			continue
+		}
+		funcs, values := ResultFuncs(doc, results)
+		buff := new(bytes.Buffer)
+		assert.NoError(t, ExecuteTemplate(buff, tmplt.Funcs(funcs), TemplateLayout.Data(doc, values)), ttext)
+		assert.Equal(t, expected, strings.TrimSpace(buff.String()), ttext)
+	}
+}
+
+type LayoutTestCase struct {
+	Layout   Layout
+	Template string
+	Expected string
+}
+
+func (ltc LayoutTestCase) Test(t *testing.T) {
+	t.Helper()
+	tmplt, err := ltc.Layout.Parse(`cmdline`, ltc.Template)
+	if !assert.NoError(t, err, ltc.Template) {
+		return
+	}
+	buff := new(bytes.Buffer)
+	assert.NoError(t, ExecuteTemplate(buff, tmplt, []any{`a`, `b`, `c`}), ltc.Template)
+	assert.Equal(t, ltc.Expected, buff.String(), ltc.Template)
+}
+
+var LayoutTestCases = []LayoutTestCase{
+	{
+		Template: `{{ . }}\n`,
+		Expected: `a\nb\nc\n`,
+	},
+	{
+		Layout:   Layout{Escapes: true},
+		Template: `{{ . }}\t{{ "\t" | quote }}\\n\n`,
+		Expected: "a\t\"\\t\"\\n\nb\t\"\\t\"\\n\nc\t\"\\t\"\\n\n",
+	},
+	{
+		Layout:   Layout{Newline: true},
+		Template: `{{ . }}`,
+		Expected: "a\nb\nc\n",
+	},
+	{
+		Layout:   Layout{Separator: `, `, Header: `[`, Footer: `] {{ len . }}`},
+		Template: `{{ . | upper }}`,
+		Expected: `[A, B, C] 3`,
+	},
+	{
+		Layout:   Layout{Escapes: true, Newline: true, Separator: `\t--`, Header: `# {{ len . }}\n`},
+		Template: `{{ . }}`,
+		Expected: "# 3\na\t--\nb\t--\nc\n",
+	},
+	{
+		Layout:   Layout{Newline: true, Separator: `,`},
+		Template: `{{ . }}`,
+		Expected: "a,\nb,\nc\n",
+	},
+}
+
+func TestLayout(t *testing.T) {
+	for _, tc := range LayoutTestCases {
+		tc.Test(t)
+	}
+	_, err := Layout{Footer: `{{ end }}`}.Parse(`cmdline`, `{{ . }}`)
+	assert.ErrorContains(t, err, `--footer`)
+}
+
+func TestTemplateModeAll(t *testing.T) {
+	doc, err := ParseDocument(`test_data/test.yaml`, FormatYAML)
+	assert.NoError(t, err)
+	results, err := EvaluateResults(doc.Data, `minerals.igneous[*]`)
+	assert.NoError(t, err)
+
+	layout := Layout{Mode: TemplateAll, Escapes: true, Newline: true, Separator: `unused`, Header: `# {{ .Filename }}\n`}
+	tmplt, err := layout.Parse(`cmdline`, `{{ .Count }} {{ .Format }}: {{ join ", " .Results }}`)
+	assert.NoError(t, err)
+	funcs, values := ResultFuncs(doc, results)
+	buff := new(bytes.Buffer)
+	assert.NoError(t, ExecuteTemplate(buff, tmplt.Funcs(funcs), layout.Data(doc, values)))
+	assert.Equal(t, "# test_data/test.yaml\n3 yaml: obsidian, granite, basalt\n", buff.String())
+
+	out, err := DefaultStyle.YAML(layout.Data(&Document{Filename: `-`, Format: FormatJSON}, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "results: []\ncount: 0\nfilename: <stdin>\nformat: json\n", string(out))
+
+	mode, err := LookupTemplateMode(`ALL`)
+	assert.NoError(t, err)
+	assert.Equal(t, TemplateAll, mode)
+	_, err = LookupTemplateMode(`some`)
+	assert.Error(t, err)
+}
+
+type LibraryTestCase struct {
+	Template      string
+	Files         []string
+	Expected      string
+	ExpectedError string
+}
+
+func (ltc LibraryTestCase) Test(t *testing.T) {
+	t.Helper()
+	tmplt, err := GetTemplate(ltc.Template, ltc.Files...)
+	if ltc.ExpectedError != `` {
+		assert.ErrorContains(t, err, ltc.ExpectedError, ltc.Files)
+		return
+	}
+	if !assert.NoError(t, err, ltc.Files) {
+		return
+	}
+	buff := new(bytes.Buffer)
+	assert.NoError(t, ExecuteTemplate(buff, tmplt, []any{`a`, `b`}), ltc.Files)
+	assert.Equal(t, ltc.Expected, buff.String(), ltc.Files)
+}
+
+var LibraryTestFiles = map[string]string{
+	`lib/rows.tmpl`:        `{{ define "row" }}<{{ . }}>{{ end }}{{ define "count" }}{{ len . }}{{ end }}`,
+	`lib/title.tmpl`:       `{{ . | upper }}`,
+	`lib/.hidden`:          `{{ end }}`,
+	`report.tmpl`:          `{{ template "row" . }}{{ template "title.tmpl" . }};`,
+	`defines.tmpl`:         "{{ define \"row\" }}[{{ . }}]{{ end }}\n{{ template \"row\" . }}",
+	`path/banner.tmpl`:     `{{ template "count" . }} results: `,
+	`path/named.tmpl`:      `{{ template "banner" $ }}{{ . }} `,
+	`path/sub/nested.tmpl`: `nested`,
+}
+
+var LibraryTestCases = []LibraryTestCase{
+	{
+		Template: `{{ template "row" . }}`,
+		Files:    []string{`lib`},
+		Expected: `<a><b>`,
+	},
+	{
+		Files:    []string{`lib`, `report.tmpl`},
+		Expected: `<a>A;<b>B;`,
+	},
+	{
+		Files:    []string{`report.tmpl`, `lib`},
+		Expected: `<a>A;<b>B;`,
+	},
+	{
+		Files:    []string{`defines.tmpl`},
+		Expected: "\n[a]\n[b]",
+	},
+	{
+		Template: `{{ template "banner" $ }}{{ . }} `,
+		Files:    []string{`lib`},
+		Expected: `2 results: a 2 results: b `,
+	},
+	{
+		Files:    []string{`lib`, `named`},
+		Expected: `2 results: a 2 results: b `,
+	},
+	{
+		Files:    []string{`sub/nested.tmpl`},
+		Expected: `nestednested`,
+	},
+	{
+		Files:         []string{`missing`},
+		ExpectedError: `STOOL_TEMPLATE_PATH`,
+	},
+	{
+		Files:         []string{`../lib/title.tmpl`},
+		ExpectedError: `STOOL_TEMPLATE_PATH`,
+	},
+}
+
+func TestTemplateLibrary(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range LibraryTestFiles {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, os.WriteFile(filename, []byte(text), 0644))
+	}
+	t.Setenv(TemplatePathVariable, filepath.Join(dir, `nope`)+string(filepath.ListSeparator)+filepath.Join(dir, `path`))
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	for _, tc := range LibraryTestCases {
+		tc.Test(t)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed usage_validate.txt
+var ValidateUsage string
+
+// Violation is a single way in which a document does not match a schema.
+type Violation struct {
+	Filename string
+	Location Location
+	Position Position
+	Message  string
+}
+
+func (v Violation) String() string {
+	if v.Position.IsValid() {
+		return fmt.Sprintf(`%s:%s: %s: %s`, v.Filename, v.Position, v.Location, v.Message)
+	}
+	return fmt.Sprintf(`%s: %s: %s`, v.Filename, v.Location, v.Message)
+}
+
+// LoadSchema reads and compiles a JSON Schema. The schema may be written in
+// any supported format. Schemas that don't declare a "$schema" are treated
+// as draft 2020-12. Only local files can be referenced with "$ref".
+func LoadSchema(filename string) (*jsonschema.Schema, error) {
+	doc, err := ParseDocument(filename, FormatUnknown)
+	if err != nil {
+		return nil, fmt.Errorf(`could not read schema: %w`, err)
+	}
+	source, err := json.Marshal(doc.Data)
+	if err != nil {
+		return nil, fmt.Errorf(`could not convert schema %q to JSON: %w`, filename, err)
+	}
+	url := filename
+	if abs, err := filepath.Abs(filename); err == nil {
+		url = `file://` + filepath.ToSlash(abs)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	if err := compiler.AddResource(url, bytes.NewReader(source)); err != nil {
+		return nil, fmt.Errorf(`could not load schema %q: %w`, filename, err)
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf(`could not compile schema %q: %w`, filename, err)
+	}
+	return schema, nil
+}
+
+// ValidateDocument checks a document against a schema and returns every
+// violation, in the order they appear in the document.
+func ValidateDocument(schema *jsonschema.Schema, doc *Document) ([]Violation, error) {
+	// The validator only understands plain Go maps.
+	data := Plain(doc.Data)
+	err := schema.Validate(data)
+	if err == nil {
+		return nil, nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return nil, fmt.Errorf(`could not validate %q: %w`, doc.Filename, err)
+	}
+	violations := make([]Violation, 0)
+	for _, leaf := range leafValidationErrors(verr, nil) {
+		loc := LocatePointer(data, leaf.InstanceLocation)
+		pos, _ := doc.Position(loc)
+		violations = append(violations, Violation{
+			Filename: doc.Filename,
+			Location: loc,
+			Position: pos,
+			Message:  leaf.Message,
+		})
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Position.Line < violations[j].Position.Line ||
+			(violations[i].Position.Line == violations[j].Position.Line && violations[i].Position.Column < violations[j].Position.Column)
+	})
+	return violations, nil
+}
+
+func leafValidationErrors(verr *jsonschema.ValidationError, leaves []*jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(verr.Causes) == 0 {
+		return append(leaves, verr)
+	}
+	for _, cause := range verr.Causes {
+		leaves = leafValidationErrors(cause, leaves)
+	}
+	return leaves
+}
+
+// LocatePointer converts a JSON Pointer into a Location, using the document
+// to tell array indexes apart from map keys that happen to be numbers.
+func LocatePointer(data any, pointer string) Location {
+	loc := Location{}
+	if pointer == `` {
+		return loc
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, `/`), `/`) {
+		token = strings.ReplaceAll(token, `~1`, `/`)
+		token = strings.ReplaceAll(token, `~0`, `~`)
+		switch container := data.(type) {
+		case []any:
+			if idx, err := strconv.Atoi(token); err == nil && idx >= 0 && idx < len(container) {
+				loc = append(loc, idx)
+				data = container[idx]
+				continue
+			}
+		case map[string]any:
+			data = container[token]
+		default:
+			data = nil
+		}
+		loc = append(loc, token)
+	}
+	return loc
+}
+
+func runValidate(args []string) error {
+	var (
+		inputFormat string
+		schemaFile  string
+		outputFile  = `-`
+	)
+	flags := newFlagSet(`validate`)
+	flags.StringVar(&schemaFile, `schema`, schemaFile, `the JSON Schema to validate against`)
+	aliasFlag(flags, `schema`, `S`)
+	flags.StringVar(&inputFormat, `format`, inputFormat, `the format of the input files; yaml|json anything else will try to auto-detect`)
+	aliasFlag(flags, `format`, `f`)
+	flags.StringVar(&outputFile, `out`, outputFile, `the file to write to or - for STDOUT`)
+	aliasFlag(flags, `out`, `o`)
+	if err := parseFlags(flags, args, ValidateUsage); err != nil {
+		return err
+	}
+
+	if schemaFile == `` {
+		return &UsageError{Err: fmt.Errorf(`no schema given; use --schema`)}
+	}
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		filenames = []string{`-`}
+	}
+	schema, err := LoadSchema(schemaFile)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if outputFile != `-` {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf(`could not create %q: %w`, outputFile, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	var invalid bool
+	for _, filename := range filenames {
+		doc, err := ParseDocument(filename, LookupFormat(inputFormat))
+		if err != nil {
+			return err
+		}
+		violations, err := ValidateDocument(schema, doc)
+		if err != nil {
+			return err
+		}
+		for _, violation := range violations {
+			invalid = true
+			if _, err := fmt.Fprintln(w, violation); err != nil {
+				return fmt.Errorf(`could not write violations: %w`, err)
+			}
+		}
+	}
+	if invalid {
+		return ExitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ValidateTestCase struct {
+	Filename   string
+	Format     Format
+	Violations []string
+}
+
+func (vtc ValidateTestCase) Test(t *testing.T) {
+	t.Helper()
+	schema, err := LoadSchema(`test_data/schema.json`)
+	assert.NoError(t, err)
+
+	doc, err := ParseDocument(vtc.Filename, vtc.Format)
+	assert.NoError(t, err, vtc.Filename)
+
+	violations, err := ValidateDocument(schema, doc)
+	assert.NoError(t, err, vtc.Filename)
+	actual := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		actual = append(actual, violation.String())
+	}
+	assert.Equal(t, vtc.Violations, actual, vtc.Filename)
+}
+
+var ValidateTestCases = []ValidateTestCase{
+	{
+		Filename:   `test_data/test.yaml`,
+		Format:     FormatYAML,
+		Violations: []string{},
+	},
+	{
+		Filename:   `test_data/test.json`,
+		Format:     FormatJSON,
+		Violations: []string{},
+	},
+	{
+		Filename: `test_data/invalid.yaml`,
+		Format:   FormatYAML,
+		Violations: []string{
+			`test_data/invalid.yaml:2:3: animals: missing properties: 'invertebrates'`,
+			`test_data/invalid.yaml:5:9: animals.vertebrates.mammals[1]: expected string, but got number`,
+			`test_data/invalid.yaml:7:15: animals.vertebrates.reptiles: minimum 1 items required, but found 0 items`,
+			`test_data/invalid.yaml:9:12: vegetables.flowers: expected array, but got string`,
+			`test_data/invalid.yaml:12:5: minerals.igneous: items at index 0 and 1 are equal`,
+		},
+	},
+	{
+		Filename: `test_data/contacts.yaml`,
+		Format:   FormatYAML,
+		Violations: []string{
+			`test_data/contacts.yaml:1:1: .: missing properties: 'animals', 'vegetables', 'minerals'`,
+		},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range ValidateTestCases {
+		tc.Test(t)
+	}
+}
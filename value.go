@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Documents are parsed into the same kinds of values whatever format they
+// were written in:
+//
+//   - maps are *Map, which keeps its keys in the order they were read
+//   - lists are []any
+//   - whole numbers that fit are int; other numbers are json.Number, so
+//     that they keep their exact value, or float64 for YAML's .inf and .nan
+//   - everything else is a string, a bool or nil
+//
+// Everything that searches, changes or writes out a document can rely on
+// this, instead of handling each parser's own types.
+
+// Map is a map with string keys that remembers the order its keys were
+// added in, so that documents are searched and written out in the order
+// they were read.
+type Map struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewMap makes an empty Map.
+func NewMap() *Map {
+	return &Map{values: make(map[string]any)}
+}
+
+// Len is the number of keys in the map.
+func (m *Map) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.keys)
+}
+
+// Keys lists the keys in order.
+func (m *Map) Keys() []string {
+	if m == nil {
+		return nil
+	}
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+// Get looks up a key.
+func (m *Map) Get(key string) (any, bool) {
+	if m == nil {
+		return nil, false
+	}
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set changes the value of a key. New keys go at the end.
+func (m *Map) Set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes a key, if it is there.
+func (m *Map) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for idx, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:idx:idx], m.keys[idx+1:]...)
+			return
+		}
+	}
+}
+
+// MarshalJSON writes the map as a JSON object with its keys in order. HTML
+// characters are left alone here; the encoder that called it escapes them
+// if it was asked to.
+func (m *Map) MarshalJSON() ([]byte, error) {
+	buff := new(bytes.Buffer)
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	buff.WriteByte('{')
+	for idx, key := range m.Keys() {
+		if idx != 0 {
+			buff.WriteByte(',')
+		}
+		if err := enc.Encode(key); err != nil {
+			return nil, err
+		}
+		buff.Truncate(buff.Len() - 1)
+		buff.WriteByte(':')
+		if err := enc.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+		buff.Truncate(buff.Len() - 1)
+	}
+	buff.WriteByte('}')
+	return buff.Bytes(), nil
+}
+
+// MarshalYAML writes the map as a YAML mapping with its keys in order.
+func (m *Map) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: `!!map`}
+	for _, key := range m.Keys() {
+		var value yaml.Node
+		if err := value.Encode(yamlNumbers(m.values[key])); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: `!!str`, Value: key}, &value)
+	}
+	return node, nil
+}
+
+// decodeJSON parses JSON into the document value model.
+func decodeJSON(data []byte) (any, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeJSONValue(dec)
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			m := NewMap()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				m.Set(key.(string), value)
+			}
+			_, err = dec.Token()
+			return m, err
+		}
+		list := make([]any, 0)
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	case json.Number:
+		return normalizeNumber(t), nil
+	default:
+		return token, nil
+	}
+}
+
+// decodeYAML parses the first document in a YAML stream into the document
+// value model.
+func decodeYAML(data []byte) (any, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	return fromYAMLNode(&node)
+}
+
+func fromYAMLNode(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return fromYAMLNode(node.Content[0])
+	case yaml.AliasNode:
+		return fromYAMLNode(node.Alias)
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, child := range node.Content {
+			value, err := fromYAMLNode(child)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		return fromYAMLMapping(node)
+	default:
+		// Numbers are checked before decoding, because the decoder fails
+		// on tagged ones that don't fit into Go's own types.
+		switch node.ShortTag() {
+		case `!!int`, `!!float`:
+			if json.Valid([]byte(node.Value)) {
+				return normalizeNumber(json.Number(node.Value)), nil
+			}
+		case `!!str`:
+			// YAML reads numbers too big for a float64, like 1e400, as
+			// strings unless they are tagged. Untagged, unquoted ones are
+			// kept as the numbers they look like.
+			if node.Style == 0 && json.Valid([]byte(node.Value)) {
+				return json.Number(node.Value), nil
+			}
+		}
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		return Normalize(value), nil
+	}
+}
+
+// fromYAMLMapping converts a mapping, including any "<<" merge keys. Keys
+// written in the mapping itself win over merged ones, and earlier merged
+// mappings win over later ones.
+func fromYAMLMapping(node *yaml.Node) (*Map, error) {
+	m := NewMap()
+	explicit := make(map[string]bool)
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if key := node.Content[idx]; key.ShortTag() != `!!merge` {
+			explicit[key.Value] = true
+		}
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		key, valueNode := node.Content[idx], node.Content[idx+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf(`line %d: only simple values can be used as keys`, key.Line)
+		}
+		value, err := fromYAMLNode(valueNode)
+		if err != nil {
+			return nil, err
+		}
+		if key.ShortTag() != `!!merge` {
+			m.Set(key.Value, value)
+			continue
+		}
+		merged := []any{value}
+		if list, ok := value.([]any); ok {
+			merged = list
+		}
+		for _, item := range merged {
+			mm, ok := item.(*Map)
+			if !ok {
+				return nil, fmt.Errorf(`line %d: only maps can be merged with <<`, key.Line)
+			}
+			for _, mkey := range mm.Keys() {
+				if _, ok := m.Get(mkey); !ok && !explicit[mkey] {
+					mvalue, _ := mm.Get(mkey)
+					m.Set(mkey, mvalue)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// normalizeNumber makes whole numbers that fit into ints, and keeps
+// everything else exact as a json.Number.
+func normalizeNumber(n json.Number) any {
+	if !strings.ContainsAny(string(n), `.eE`) {
+		if i, err := strconv.Atoi(string(n)); err == nil {
+			return i
+		}
+	}
+	return n
+}
+
+// Normalize converts a value built by Go code, or decoded by a package
+// that doesn't keep order, into the document value model. Keys of plain Go
+// maps are put in sorted order, because they don't have one of their own.
+func Normalize(value any) any {
+	return normalize(value, sortedKeys)
+}
+
+// normalize is Normalize, with keys giving the order of the keys of each
+// plain Go map.
+func normalize(value any, keys func(map[string]any) []string) any {
+	switch v := value.(type) {
+	case *Map:
+		out := NewMap()
+		for _, key := range v.keys {
+			out.Set(key, normalize(v.values[key], keys))
+		}
+		return out
+	case map[string]any:
+		out := NewMap()
+		for _, key := range keys(v) {
+			out.Set(key, normalize(v[key], keys))
+		}
+		return out
+	case map[any]any:
+		byName := make(map[string]any, len(v))
+		for key, item := range v {
+			byName[fmt.Sprint(key)] = item
+		}
+		return normalize(byName, keys)
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = normalize(item, keys)
+		}
+		return out
+	case json.Number:
+		return normalizeNumber(v)
+	case int64:
+		return normalizeNumber(json.Number(strconv.FormatInt(v, 10)))
+	case uint64:
+		return normalizeNumber(json.Number(strconv.FormatUint(v, 10)))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return v
+		}
+		return normalizeNumber(json.Number(strconv.FormatFloat(v, 'g', -1, 64)))
+	default:
+		return value
+	}
+}
+
+// Plain converts a document value into plain Go maps, for code that needs
+// them. Templates, which can only look up the keys of real maps, use
+// templateValues.
+func Plain(value any) any {
+	switch v := value.(type) {
+	case *Map:
+		out := make(map[string]any, v.Len())
+		for _, key := range v.keys {
+			out[key] = Plain(v.values[key])
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = Plain(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// templateValues converts document values for one rendering of a
+// template. Templates can only look up the keys of real Go maps, which
+// have no order, so it remembers the order of the keys of each map it
+// makes, and its Normalize puts them back in that order. Each map is kept
+// with its order, so that its address can't be reused by another map.
+type templateValues struct {
+	maps map[uintptr]orderedKeys
+}
+
+type orderedKeys struct {
+	m    map[string]any
+	keys []string
+}
+
+// Value is Plain for templates. Numbers are also converted into Go's own
+// types where they can be, so that templates can compare them.
+func (tv *templateValues) Value(value any) any {
+	switch v := value.(type) {
+	case *Map:
+		out := make(map[string]any, v.Len())
+		for _, key := range v.keys {
+			out[key] = tv.Value(v.values[key])
+		}
+		if tv.maps == nil {
+			tv.maps = make(map[uintptr]orderedKeys)
+		}
+		tv.maps[reflect.ValueOf(out).Pointer()] = orderedKeys{m: out, keys: v.keys}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for idx, item := range v {
+			out[idx] = tv.Value(item)
+		}
+		return out
+	case json.Number:
+		return templateNumber(v)
+	default:
+		return value
+	}
+}
+
+// Normalize is Normalize for values from a template, which puts the keys
+// of maps made by Value in their order in the document.
+func (tv *templateValues) Normalize(value any) any {
+	return normalize(value, tv.keys)
+}
+
+// keys lists the keys of m in the order they had in the document, if m
+// was made by Value. Keys a template has added since are sorted after
+// them.
+func (tv *templateValues) keys(m map[string]any) []string {
+	known := tv.maps[reflect.ValueOf(m).Pointer()].keys
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(known))
+	for _, key := range known {
+		if _, ok := m[key]; ok {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	for _, key := range sortedKeys(m) {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ValueTestCase struct {
+	Source       string
+	Decode       Unmarshaller
+	ExpectedKeys []string
+	ExpectedJSON string
+}
+
+func (vtc ValueTestCase) Test(t *testing.T) {
+	t.Helper()
+	value, err := vtc.Decode([]byte(vtc.Source))
+	assert.NoError(t, err, vtc.Source)
+	m, ok := value.(*Map)
+	if !assert.True(t, ok, vtc.Source) {
+		return
+	}
+	assert.Equal(t, vtc.ExpectedKeys, m.Keys(), vtc.Source)
+	out, err := json.Marshal(m)
+	assert.NoError(t, err, vtc.Source)
+	assert.Equal(t, vtc.ExpectedJSON, string(out), vtc.Source)
+}
+
+var ValueTestCases = []ValueTestCase{
+	{
+		Source:       `{"z": 1, "a": [2.50, 3], "m": {"y": null, "b": true}}`,
+		Decode:       decodeJSON,
+		ExpectedKeys: []string{`z`, `a`, `m`},
+		ExpectedJSON: `{"z":1,"a":[2.50,3],"m":{"y":null,"b":true}}`,
+	},
+	{
+		Source:       "z: 1\na: [2.50, 0x10]\nm: {y: ~, b: yes}\n",
+		Decode:       decodeYAML,
+		ExpectedKeys: []string{`z`, `a`, `m`},
+		ExpectedJSON: `{"z":1,"a":[2.50,16],"m":{"y":null,"b":"yes"}}`,
+	},
+	{
+		Source:       "1: one\ntrue: yes\n",
+		Decode:       decodeYAML,
+		ExpectedKeys: []string{`1`, `true`},
+		ExpectedJSON: `{"1":"one","true":"yes"}`,
+	},
+	{
+		Source:       "base: &base {a: 1, b: 2}\nmore: &more {c: 3, a: 4}\nchild:\n  b: 5\n  <<: [*base, *more]\n",
+		Decode:       decodeYAML,
+		ExpectedKeys: []string{`base`, `more`, `child`},
+		ExpectedJSON: `{"base":{"a":1,"b":2},"more":{"c":3,"a":4},"child":{"b":5,"a":1,"c":3}}`,
+	},
+	{
+		Source:       `{"big": 12345678901234567890, "exp": 1e3, "html": "<b>"}`,
+		Decode:       decodeJSON,
+		ExpectedKeys: []string{`big`, `exp`, `html`},
+		ExpectedJSON: `{"big":12345678901234567890,"exp":1e3,"html":"\u003cb\u003e"}`,
+	},
+	{
+		Source:       "int: !!int 123456789012345678901234567890\nfloat: !!float 1e400\n",
+		Decode:       decodeYAML,
+		ExpectedKeys: []string{`int`, `float`},
+		ExpectedJSON: `{"int":123456789012345678901234567890,"float":1e400}`,
+	},
+}
+
+func TestValues(t *testing.T) {
+	for _, tc := range ValueTestCases {
+		tc.Test(t)
+	}
+	_, err := decodeYAML([]byte("[a, b]: 1\n"))
+	assert.Error(t, err)
+
+	m := NewMap()
+	m.Set(`b`, 1)
+	m.Set(`a`, 2)
+	m.Set(`b`, 3)
+	m.Delete(`nope`)
+	assert.Equal(t, []string{`b`, `a`}, m.Keys())
+	m.Delete(`b`)
+	assert.Equal(t, []string{`a`}, m.Keys())
+
+	out, err := DefaultStyle.YAML(Normalize(map[string]any{`z`: 1, `a`: map[any]any{2: 1.5}}))
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n    \"2\": 1.5\nz: 1\n", string(out))
+}